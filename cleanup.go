@@ -3,27 +3,314 @@ package main
 import (
 	"debug/elf"
 	"debug/macho"
+	"debug/pe"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"runtime"
-	"io/fs"
+	"strings"
+
+	"github.com/kavishgr/getghrel/utils"
 )
 
-func cleanup(tempdir string) error {
-	var verifyFile func(file *os.File) error
+// cleanupOptions are the settings of cleanup shared by every target
+type cleanupOptions struct {
+	// the asset each file came from
+	origins map[string]string
+	// reduce universal macOS binaries to a single architecture
+	thin bool
+	// the kinds of files to keep: binary, appimage and/or script
+	keep map[string]bool
+	// tree layout: keep every file and expose the executables in tempdir/bin
+	tree bool
+}
+
+// kinds of files cleanup can keep
+var fileKinds = []string{"binary", "appimage", "script"}
+
+// returned for files that are not in the binary format of the target
+var errNotBinary = errors.New("not a binary")
+
+// machine, class (32/64-bit) and byte order of the ELF binaries of each GOARCH
+var elfArches = map[string]struct {
+	machine elf.Machine
+	class   elf.Class
+	data    elf.Data
+}{
+	"386":      {elf.EM_386, elf.ELFCLASS32, elf.ELFDATA2LSB},
+	"amd64":    {elf.EM_X86_64, elf.ELFCLASS64, elf.ELFDATA2LSB},
+	"arm":      {elf.EM_ARM, elf.ELFCLASS32, elf.ELFDATA2LSB},
+	"arm64":    {elf.EM_AARCH64, elf.ELFCLASS64, elf.ELFDATA2LSB},
+	"loong64":  {elf.EM_LOONGARCH, elf.ELFCLASS64, elf.ELFDATA2LSB},
+	"mips":     {elf.EM_MIPS, elf.ELFCLASS32, elf.ELFDATA2MSB},
+	"mipsle":   {elf.EM_MIPS, elf.ELFCLASS32, elf.ELFDATA2LSB},
+	"mips64":   {elf.EM_MIPS, elf.ELFCLASS64, elf.ELFDATA2MSB},
+	"mips64le": {elf.EM_MIPS, elf.ELFCLASS64, elf.ELFDATA2LSB},
+	"ppc64":    {elf.EM_PPC64, elf.ELFCLASS64, elf.ELFDATA2MSB},
+	"ppc64le":  {elf.EM_PPC64, elf.ELFCLASS64, elf.ELFDATA2LSB},
+	"riscv64":  {elf.EM_RISCV, elf.ELFCLASS64, elf.ELFDATA2LSB},
+	"s390x":    {elf.EM_S390, elf.ELFCLASS64, elf.ELFDATA2MSB},
+}
+
+var machoCpus = map[string]macho.Cpu{
+	"amd64": macho.CpuAmd64,
+	"arm64": macho.CpuArm64,
+}
+
+var peMachines = map[string]uint16{
+	"386":   pe.IMAGE_FILE_MACHINE_I386,
+	"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+	"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+}
+
+/*
+- Verify an ELF file is an executable for the architecture.
+
+- Position independent executables are ET_DYN like shared libraries,
+they are told apart by their dynamic loader (PT_INTERP)
+or the DF_1_PIE flag for static-pie binaries.
+*/
+func verifyELF(file *os.File, arch string) error {
+	f, err := elf.NewFile(file)
+	if err != nil {
+		return errNotBinary
+	}
+
+	want := elfArches[arch]
+	if f.Machine != want.machine || f.Class != want.class || f.Data != want.data {
+		got := strings.TrimPrefix(f.Machine.String(), "EM_")
+		return fmt.Errorf("built for %s (%s, %s), want %s", got, f.Class, f.Data, arch)
+	}
+
+	switch f.Type {
+	case elf.ET_EXEC:
+		return nil
+	case elf.ET_DYN:
+		for _, prog := range f.Progs {
+			if prog.Type == elf.PT_INTERP {
+				return nil
+			}
+		}
+		flags, err := f.DynValue(elf.DT_FLAGS_1)
+		if err == nil && len(flags) > 0 && flags[0]&uint64(elf.DF_1_PIE) != 0 {
+			return nil
+		}
+		return fmt.Errorf("shared library, not an executable")
+	default:
+		return fmt.Errorf("%s, not an executable", f.Type)
+	}
+}
+
+/*
+- Verify a Mach-O file is an executable for the architecture.
+
+- Universal (fat) binaries are kept when they have
+a slice for the architecture.
+*/
+func verifyMachO(file *os.File, arch string) error {
+	want, ok := machoCpus[arch]
+	if !ok {
+		return fmt.Errorf("no Mach-O cpu for %s", arch)
+	}
+
+	f, err := macho.NewFile(file)
+	if err != nil {
+		return verifyFatMachO(file, want, arch)
+	}
+	if f.Cpu != want {
+		return fmt.Errorf("built for %s, want %s", f.Cpu, arch)
+	}
+	if f.Type != macho.TypeExec {
+		return fmt.Errorf("%s, not an executable", f.Type)
+	}
+	return nil
+}
+
+func verifyFatMachO(file *os.File, want macho.Cpu, arch string) error {
+	fat, err := macho.NewFatFile(file)
+	if err != nil {
+		return errNotBinary
+	}
+
+	var cpus []string
+	for _, a := range fat.Arches {
+		if a.Cpu != want {
+			cpus = append(cpus, a.Cpu.String())
+			continue
+		}
+		if a.Type != macho.TypeExec {
+			return fmt.Errorf("%s, not an executable", a.Type)
+		}
+		return nil
+	}
+	return fmt.Errorf("universal binary for %s, without a %s slice", strings.Join(cpus, ", "), arch)
+}
+
+/*
+- Replace a universal (fat) binary by its slice for the architecture,
+to save disk space. Nothing is done if binpath is not a universal binary.
+
+- The slice is written next to the binary, then renamed over it.
+*/
+func thinMachO(binpath, arch string) error {
+	fat, err := macho.OpenFat(binpath)
+	if err != nil {
+		// not a universal binary
+		return nil
+	}
+	defer fat.Close()
+
+	for _, a := range fat.Arches {
+		if a.Cpu != machoCpus[arch] {
+			continue
+		}
 
-	switch runtime.GOOS {
-	case "linux":
-		verifyFile = func(file *os.File) error {
-			_, err := elf.NewFile(file)
+		src, err := os.Open(binpath)
+		if err != nil {
 			return err
 		}
-	case "darwin":
-		verifyFile = func(file *os.File) error {
-			_, err := macho.NewFile(file)
+		defer src.Close()
+
+		tmp, err := os.CreateTemp(filepath.Dir(binpath), ".thin-*")
+		if err != nil {
 			return err
 		}
+		defer os.Remove(tmp.Name()) // no-op once renamed
+
+		_, err = io.Copy(tmp, io.NewSectionReader(src, int64(a.Offset), int64(a.Size)))
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), binpath)
+	}
+	return fmt.Errorf("no %s slice", arch)
+}
+
+// Verify a PE file is an executable (not a DLL) for the architecture
+func verifyPE(file *os.File, arch string) error {
+	f, err := pe.NewFile(file)
+	if err != nil {
+		return errNotBinary
+	}
+	if want, ok := peMachines[arch]; !ok || f.Machine != want {
+		return fmt.Errorf("built for machine %#x, want %s", f.Machine, arch)
+	}
+	if f.Characteristics&pe.IMAGE_FILE_DLL != 0 {
+		return fmt.Errorf("DLL, not an executable")
+	}
+	if f.Characteristics&pe.IMAGE_FILE_EXECUTABLE_IMAGE == 0 {
+		return fmt.Errorf("not an executable image")
+	}
+	return nil
+}
+
+// ELF files with "AI" and the AppImage type (1 or 2) in the padding of their header
+func isAppImage(f *os.File) bool {
+	magic := make([]byte, 3)
+	if _, err := f.ReadAt(magic, 8); err != nil {
+		return false
+	}
+	return magic[0] == 'A' && magic[1] == 'I' && (magic[2] == 1 || magic[2] == 2)
+}
+
+// scripts start with a shebang, like "#!/bin/sh" or "#!/usr/bin/env python3"
+func isScript(f *os.File) bool {
+	shebang := make([]byte, 2)
+	if _, err := f.ReadAt(shebang, 0); err != nil {
+		return false
+	}
+	return string(shebang) == "#!"
+}
+
+/*
+- Return the kind of a file from the result of verifyFile:
+"binary" or "appimage" for an executable of the target,
+"script" for a file starting with a shebang,
+and an empty string for anything else.
+*/
+func fileKind(f *os.File, verifyErr error) string {
+	switch {
+	case verifyErr == nil && isAppImage(f):
+		return "appimage"
+	case verifyErr == nil:
+		return "binary"
+	case errors.Is(verifyErr, errNotBinary) && isScript(f):
+		return "script"
+	}
+	return ""
+}
+
+// Return the asset a file came from
+func origin(origins map[string]string, binpath string) string {
+	if asset, ok := origins[binpath]; ok {
+		return asset
+	}
+	return "unknown asset"
+}
+
+/*
+- Expose an executable of the tree layout with a symlink in tempdir/bin,
+for e.g /tmp/getghrel/bin/hx -> ../helix/24.03/hx
+
+- If another executable already has the same name, it's not replaced.
+*/
+func expose(tempdir, binpath string) error {
+	bindir := filepath.Join(tempdir, "bin")
+	if err := os.MkdirAll(bindir, 0755); err != nil {
+		return err
+	}
+
+	link := filepath.Join(bindir, filepath.Base(binpath))
+	target, err := filepath.Rel(bindir, binpath)
+	if err != nil {
+		return err
+	}
+
+	if existing, err := os.Readlink(link); err == nil {
+		if existing != target {
+			fmt.Printf("Warning: %s is already exposed by %s, skipping %s\n", filepath.Base(binpath), existing, target)
+		}
+		return nil
+	}
+	return os.Symlink(target, link)
+}
+
+/*
+- Keep only the executables built for the target platform inside tempdir.
+
+- Files that are not binaries are removed. Binaries built for another
+architecture, shared libraries and other non-executables are removed as well,
+with a warning naming the asset (from opts.origins) they came from.
+
+- With opts.thin, universal macOS binaries are reduced to the target's slice.
+
+- Binaries, AppImages and scripts (with a shebang) are kept
+only if their kind is in opts.keep.
+
+- With opts.tree (the tree layout), nothing is removed:
+the executables are exposed in tempdir/bin instead.
+*/
+func cleanup(tempdir string, target utils.Platform, opts cleanupOptions) error {
+	var verifyFile func(file *os.File, arch string) error
+
+	switch target.OS {
+	case "darwin":
+		verifyFile = verifyMachO
+	case "windows":
+		verifyFile = verifyPE
+	// linux, the BSDs, android, illumos and solaris all use ELF
+	default:
+		verifyFile = verifyELF
+	}
+
+	// nothing was downloaded for the target
+	if _, err := os.Stat(tempdir); os.IsNotExist(err) {
+		return nil
 	}
 
 	err := filepath.WalkDir(tempdir, func(binpath string, d fs.DirEntry, err error) error {
@@ -32,8 +319,13 @@ func cleanup(tempdir string) error {
 		}
 
 		if binpath == tempdir {
-      		return nil
-    	}
+			return nil
+		}
+
+		// the executables exposed by the tree layout
+		if opts.tree && d.IsDir() && binpath == filepath.Join(tempdir, "bin") {
+			return filepath.SkipDir
+		}
 
 		// If a directory - skip
 		if d.IsDir() {
@@ -43,21 +335,60 @@ func cleanup(tempdir string) error {
 
 		// Open the file
 		f, err := os.Open(binpath)
-		defer f.Close()
 		if err != nil {
 			return err
 		}
+		defer f.Close()
+
+		// Verify if the open file is an executable (ELF, Mach-O or PE) for the target
+		err = verifyFile(f, target.Arch)
+
+		kind := fileKind(f, err)
 
-		// Verify if the open file is either an ELF or Mach-O
-		if err := verifyFile(f); err == nil {
+		// the tree layout keeps every file, the runtime files of a tool included
+		if opts.tree {
+			if kind == "" || !opts.keep[kind] {
+				if err != nil && !errors.Is(err, errNotBinary) {
+					fmt.Printf("Warning: %s (from %s): %v, not exposed\n", filepath.Base(binpath), origin(opts.origins, binpath), err)
+				}
+				return nil
+			}
+			if err := os.Chmod(binpath, 0755); err != nil {
+				return err
+			}
+			return expose(tempdir, binpath)
+		}
+
+		if kind != "" && !opts.keep[kind] {
+			// fmt.Println("Not kept: ", binpath) // comment
+			f.Close()
+			os.Remove(binpath)
+			return nil
+		}
+
+		switch {
+		case kind == "script":
+			err = os.Chmod(binpath, 0755)
+			if err != nil {
+				return err
+			}
+		case err == nil:
+			if opts.thin && target.OS == "darwin" {
+				if err := thinMachO(binpath, target.Arch); err != nil {
+					fmt.Printf("Warning: %s: could not thin the universal binary: %v\n", filepath.Base(binpath), err)
+				}
+			}
 			err = os.Chmod(binpath, 0755)
 			// fmt.Println("Binary: ", binpath) //comment
 			if err != nil {
 				return err
 			}
-		} else {
+		case errors.Is(err, errNotBinary):
 			// fmt.Println("Removing: ", binpath) // comment
 			os.Remove(binpath)
+		default:
+			fmt.Printf("Warning: %s (from %s): %v, removed\n", filepath.Base(binpath), origin(opts.origins, binpath), err)
+			os.Remove(binpath)
 		}
 		return nil
 	})
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/kavishgr/getghrel/github"
+	"github.com/kavishgr/getghrel/utils"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+Tool is an entry of the tools list of the tools file:
+
+	repo: mikefarah/yq
+	version: ^4.40           # a tag, or a semver constraint (latest release by default)
+	asset: "yq_*.tar.gz"     # only the assets matching the pattern are considered
+	rename: yq               # the name the executable is installed as
+	targets: [linux/amd64]   # the platforms it's installed on (every platform by default)
+	layout: tree             # flat (default) or tree
+	policy: allow-prerelease # stable-only (default), allow-prerelease or nightly
+*/
+type Tool struct {
+	Repo    string   `yaml:"repo"`
+	Version string   `yaml:"version"`
+	Asset   string   `yaml:"asset"`
+	Rename  string   `yaml:"rename"`
+	Targets []string `yaml:"targets"`
+	Layout  string   `yaml:"layout"`
+	Policy  string   `yaml:"policy"`
+}
+
+// Config is a tools file, the set of tools getghrel sync installs
+type Config struct {
+	// the platforms of every tool without targets of its own
+	Targets []string `yaml:"targets"`
+	Tools   []Tool   `yaml:"tools"`
+}
+
+// Load and validate the tools file at path. Unknown keys are errors, they're usually typos
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *Config) validate() error {
+	for _, target := range c.Targets {
+		if _, err := utils.ParsePlatform(target); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range c.Tools {
+		parts := strings.Split(t.Repo, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("repo %q is not in the owner/repo format", t.Repo)
+		}
+		if seen[t.Repo] {
+			return fmt.Errorf("%s is listed twice", t.Repo)
+		}
+		seen[t.Repo] = true
+
+		if _, err := path.Match(t.Asset, ""); err != nil {
+			return fmt.Errorf("%s: asset %q: %w", t.Repo, t.Asset, err)
+		}
+		if strings.ContainsAny(t.Rename, `/\`) {
+			return fmt.Errorf("%s: rename %q is not a file name", t.Repo, t.Rename)
+		}
+		if _, err := github.ParsePolicy(t.Policy); err != nil {
+			return fmt.Errorf("%s: %w", t.Repo, err)
+		}
+		if t.Layout != "" && t.Layout != "flat" && t.Layout != "tree" {
+			return fmt.Errorf("%s: layout %q is not supported, use: flat, tree", t.Repo, t.Layout)
+		}
+		for _, target := range t.Targets {
+			if _, err := utils.ParsePlatform(target); err != nil {
+				return fmt.Errorf("%s: %w", t.Repo, err)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+- Return the platform a tool is installed on, when getghrel runs on host.
+
+- ok is false if the host is not one of the targets of the tool
+(or of the file when the tool has none). A target without an arm
+variant (linux/arm) matches every variant.
+*/
+func (c *Config) Platform(t Tool, host utils.Platform) (utils.Platform, bool) {
+	targets := t.Targets
+	if len(targets) == 0 {
+		targets = c.Targets
+	}
+	if len(targets) == 0 {
+		return host, true
+	}
+	for _, target := range targets {
+		p, err := utils.ParsePlatform(target)
+		if err != nil {
+			continue
+		}
+		if p.OS == host.OS && p.Arch == host.Arch && (p.Variant == "" || p.Variant == host.Variant) {
+			return host, true
+		}
+	}
+	return utils.Platform{}, false
+}
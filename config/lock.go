@@ -0,0 +1,140 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kavishgr/getghrel/utils"
+)
+
+// LockedAsset is the asset of a tool pinned for a platform
+type LockedAsset struct {
+	Asset  string `json:"asset"`
+	URL    string `json:"url"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// the libc a linux asset was resolved for: gnu, musl or any
+	Libc string `json:"libc,omitempty"`
+}
+
+// Return true if the asset was resolved for the libc of the platform.
+// The linux assets of lockfiles without a libc were resolved for any libc
+func (a LockedAsset) LockedFor(p utils.Platform) bool {
+	if p.OS != "linux" {
+		return true
+	}
+	libc := a.Libc
+	if libc == "" {
+		libc = "any"
+	}
+	return libc == p.Libc
+}
+
+// LockedTool is a tool of the tools file, pinned to an exact release
+type LockedTool struct {
+	// the version, asset pattern and policy of the tools file it was resolved from,
+	// to tell when the lockfile is out of date
+	Version string `json:"version,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Policy  string `json:"policy,omitempty"`
+
+	Tag       string                 `json:"tag"`
+	Platforms map[string]LockedAsset `json:"platforms"` // os/arch[/variant] -> asset
+}
+
+// Lock is the lockfile of a tools file, written by getghrel lock
+type Lock struct {
+	Tools map[string]LockedTool `json:"tools"` // owner/repo -> tool
+}
+
+// Return the path of the lockfile of a tools file: tools.yaml -> tools.lock
+func LockPath(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file)) + ".lock"
+}
+
+// Load the lockfile at path
+func LoadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &Lock{}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Save the lockfile at path, atomically
+func (l *Lock) Save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Return the platforms a tool is locked for: its targets, the targets of the file,
+// or the platform getghrel is running on when neither has any
+func (c *Config) LockPlatforms(t Tool, host utils.Platform) ([]utils.Platform, error) {
+	targets := t.Targets
+	if len(targets) == 0 {
+		targets = c.Targets
+	}
+	if len(targets) == 0 {
+		targets = []string{host.String()}
+	}
+
+	var platforms []utils.Platform
+	for _, target := range targets {
+		p, err := utils.ParsePlatform(target)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
+
+// Return true if the tool was locked from the same version, asset pattern and policy
+func (locked LockedTool) Matches(t Tool) bool {
+	return locked.Version == t.Version && locked.Pattern == t.Asset && locked.Policy == t.Policy
+}
+
+/*
+- Return the asset a tool is locked to for the platform.
+
+- An error is returned when the tool is missing from the lockfile,
+when it was locked from another version, asset pattern or policy than the one
+of the tools file (the lockfile is out of date), or when it was not locked for the platform
+or its libc. A tool locked for linux/arm is used on every arm variant.
+*/
+func (l *Lock) Asset(t Tool, p utils.Platform) (string, LockedAsset, error) {
+	locked, ok := l.Tools[t.Repo]
+	switch {
+	case !ok:
+		return "", LockedAsset{}, errors.New("not in the lockfile, run getghrel lock")
+	case !locked.Matches(t):
+		return "", LockedAsset{}, errors.New("the lockfile is out of date, run getghrel lock")
+	}
+
+	asset, ok := locked.Platforms[p.String()]
+	if !ok {
+		asset, ok = locked.Platforms[p.OS+"/"+p.Arch]
+	}
+	switch {
+	case !ok:
+		return "", LockedAsset{}, fmt.Errorf("not locked for %s", p)
+	case !asset.LockedFor(p):
+		return "", LockedAsset{}, fmt.Errorf("not locked for the %s libc, run getghrel lock", p.Libc)
+	}
+	return locked.Tag, asset, nil
+}
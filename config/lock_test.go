@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/kavishgr/getghrel/utils"
+)
+
+func TestLockAsset(t *testing.T) {
+	tool := Tool{Repo: "owner/tool", Version: "^1.0"}
+	lock := &Lock{Tools: map[string]LockedTool{
+		"owner/tool": {
+			Version: "^1.0",
+			Tag:     "v1.2.0",
+			Platforms: map[string]LockedAsset{
+				"linux/amd64":  {Asset: "tool-x86_64-unknown-linux-gnu.tar.gz", Libc: "gnu"},
+				"linux/arm64":  {Asset: "tool-aarch64-unknown-linux-musl.tar.gz"},
+				"linux/arm":    {Asset: "tool-linux-armv6.tar.gz", Libc: "any"},
+				"darwin/arm64": {Asset: "tool-aarch64-apple-darwin.tar.gz"},
+			},
+		},
+	}}
+	tests := []struct {
+		name    string
+		tool    Tool
+		p       utils.Platform
+		asset   string
+		wantErr bool
+	}{
+		{name: "same libc", tool: tool, p: utils.Platform{OS: "linux", Arch: "amd64", Libc: "gnu"}, asset: "tool-x86_64-unknown-linux-gnu.tar.gz"},
+		{name: "another libc", tool: tool, p: utils.Platform{OS: "linux", Arch: "amd64", Libc: "musl"}, wantErr: true},
+		{name: "locked without a libc is any", tool: tool, p: utils.Platform{OS: "linux", Arch: "arm64", Libc: "any"}, asset: "tool-aarch64-unknown-linux-musl.tar.gz"},
+		{name: "locked without a libc on the host", tool: tool, p: utils.Platform{OS: "linux", Arch: "arm64", Libc: "gnu"}, wantErr: true},
+		{name: "every arm variant", tool: tool, p: utils.Platform{OS: "linux", Arch: "arm", Variant: "v7", Libc: "any"}, asset: "tool-linux-armv6.tar.gz"},
+		{name: "no libc outside linux", tool: tool, p: utils.Platform{OS: "darwin", Arch: "arm64", Libc: "any"}, asset: "tool-aarch64-apple-darwin.tar.gz"},
+		{name: "not locked for the platform", tool: tool, p: utils.Platform{OS: "windows", Arch: "amd64", Libc: "any"}, wantErr: true},
+		{name: "out of date", tool: Tool{Repo: "owner/tool", Version: "^2.0"}, p: utils.Platform{OS: "darwin", Arch: "arm64"}, wantErr: true},
+		{name: "not in the lockfile", tool: Tool{Repo: "owner/other"}, p: utils.Platform{OS: "darwin", Arch: "arm64"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tag, asset, err := lock.Asset(tt.tool, tt.p)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: Asset() = %s, want an error", tt.name, asset.Asset)
+			}
+			continue
+		}
+		if err != nil || tag != "v1.2.0" || asset.Asset != tt.asset {
+			t.Errorf("%s: Asset() = %s %s, %v, want v1.2.0 %s", tt.name, tag, asset.Asset, err, tt.asset)
+		}
+	}
+}
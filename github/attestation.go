@@ -0,0 +1,44 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kavishgr/getghrel/verify"
+	"github.com/tidwall/gjson"
+)
+
+/*
+- Fetch the attestations of the asset with the sha256 digest (hex) from
+the github attestations api of repo (owner/repo), and verify them with keys
+(see verify.Keys.VerifyAttestation).
+
+- Return the provenance of the first attestation that verifies,
+an error when the asset has none or none verifies.
+*/
+func verifyAttestation(ghtoken string, keys *verify.Keys, repo, digest string) (verify.Provenance, error) {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/attestations/sha256:%s", repo, digest)
+	body, status, err := getAPI(ghtoken, u)
+	switch {
+	case err != nil:
+		return verify.Provenance{}, err
+	case status == http.StatusNotFound:
+		return verify.Provenance{}, errors.New("no attestation for the asset")
+	case status != http.StatusOK:
+		return verify.Provenance{}, fmt.Errorf("%s: %s", u, gjson.GetBytes(body, "message").String())
+	}
+
+	err = errors.New("no attestation for the asset")
+	for _, a := range gjson.GetBytes(body, "attestations").Array() {
+		bundle := a.Get("bundle")
+		if !bundle.Exists() {
+			continue
+		}
+		var p verify.Provenance
+		if p, err = keys.VerifyAttestation(repo, digest, []byte(bundle.Raw)); err == nil {
+			return p, nil
+		}
+	}
+	return verify.Provenance{}, err
+}
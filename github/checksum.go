@@ -0,0 +1,192 @@
+package github
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/kavishgr/getghrel/utils"
+)
+
+// Digest is the checksum of an asset, as found in a checksum file of its release
+type Digest struct {
+	Algo string // sha256 or sha512
+	Hex  string
+	File string // the checksum file it was found in
+	data []byte // the checksum file itself, its signature covers the asset
+}
+
+// the biggest checksum file that is downloaded
+const maxChecksumFile = 1 << 20
+
+// suffixes of the checksum file of a single asset: bat.tar.gz.sha256
+var checksumSuffixes = []string{".sha256", ".sha256sum", ".sha256.txt", ".sha512", ".sha512sum", ".sha512.txt"}
+
+// suffixes of signatures and certificates, which can be named like checksum files (checksums.txt.sig)
+var signatureSuffixes = []string{".sig", ".asc", ".gpg", ".pem", ".crt", ".cert", ".minisig", ".bundle", ".sigstore", ".json"}
+
+// Return a new hash.Hash for the algorithm of the digest
+func (d Digest) newHash() hash.Hash {
+	if d.Algo == "sha512" {
+		return sha512.New()
+	}
+	return sha256.New()
+}
+
+/*
+- Return the checksum files of a release that can list the asset,
+in the order they're tried: the checksum file of the asset itself
+(<asset>.sha256, <asset>.sha512...) first, then the checksum files
+of the whole release (checksums.txt, SHA256SUMS, <tool>_checksums.txt...).
+*/
+func checksumFiles(release Release, asset string) []utils.Asset {
+	var own, shared []utils.Asset
+	for _, a := range release.Assets {
+		name := strings.ToLower(a.Name)
+		if hasSuffix(name, signatureSuffixes) {
+			continue
+		}
+		switch {
+		case ownChecksumFile(name, asset):
+			own = append(own, a)
+		case strings.Contains(name, "checksum") || strings.Contains(name, "sha256sum") || strings.Contains(name, "sha512sum"):
+			shared = append(shared, a)
+		}
+	}
+	return append(own, shared...)
+}
+
+// Return true if name is the checksum file of asset itself: <asset>.sha256, <asset>.sha512...
+// and not the one of another asset starting like it (tool-arm64.sha256 for tool)
+func ownChecksumFile(name, asset string) bool {
+	for _, s := range checksumSuffixes {
+		if strings.EqualFold(name, asset+s) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSuffix(name string, suffixes []string) bool {
+	for _, s := range suffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the algorithm of a hex digest going by its length, empty if it's not one
+func digestAlgo(s string) string {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return ""
+		}
+	}
+	switch len(s) {
+	case 64:
+		return "sha256"
+	case 128:
+		return "sha512"
+	}
+	return ""
+}
+
+/*
+- Find the digest of asset in a checksum file.
+
+- Lines are either "<hex>  <name>" (sha256sum, with a "*" before the name in binary mode)
+or "SHA256 (<name>) = <hex>" (BSD). The checksum file of a single asset
+can also be the hex digest alone (single is true for those).
+*/
+func parseChecksums(data []byte, asset string, single bool) (algo, hex string, ok bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 1 && single:
+			hex = fields[0]
+		case len(fields) >= 2 && digestAlgo(fields[0]) != "":
+			// the checksum file of the asset itself can name it differently
+			name := strings.TrimPrefix(strings.Join(fields[1:], " "), "*")
+			if path.Base(name) != asset && !single {
+				continue
+			}
+			hex = fields[0]
+		case len(fields) == 4 && fields[2] == "=":
+			// SHA256 (bat.tar.gz) = <hex>
+			name := strings.TrimSuffix(strings.TrimPrefix(fields[1], "("), ")")
+			if path.Base(name) != asset {
+				continue
+			}
+			hex = fields[3]
+		default:
+			continue
+		}
+		if algo = digestAlgo(hex); algo != "" {
+			return algo, strings.ToLower(hex), true
+		}
+	}
+	return "", "", false
+}
+
+// Download a file of a release, up to maxChecksumFile bytes
+func downloadSmall(ghtoken, u string) ([]byte, error) {
+	req, err := craftDownloadReq(ghtoken, u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", path.Base(u), resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxChecksumFile))
+}
+
+// Fetch the release with the tag in repo (owner/repo), an error if there's none
+func fetchRelease(ghtoken, repo, tag string) (Release, error) {
+	release, found, err := ReleaseByTag(ghtoken, repo, tag)
+	if err != nil {
+		return Release{}, err
+	}
+	if !found {
+		return Release{}, fmt.Errorf("no release %s in %s", tag, repo)
+	}
+	return release, nil
+}
+
+/*
+- Look up the digest of an asset in the checksum files of its release.
+
+- unverified says why the asset can't be verified: no checksum file
+in the release, or none listing the asset. err is set when a checksum file
+can't be downloaded, the asset must not be used then.
+*/
+func findDigest(ghtoken string, release Release, asset string) (digest Digest, unverified, err error) {
+	files := checksumFiles(release, asset)
+	if len(files) == 0 {
+		return Digest{}, errors.New("no checksum file in the release"), nil
+	}
+	for _, f := range files {
+		data, err := downloadSmall(ghtoken, f.DownloadURL())
+		if err != nil {
+			return Digest{}, nil, fmt.Errorf("checksum file %s: %w", f.Name, err)
+		}
+		if algo, hex, ok := parseChecksums(data, asset, ownChecksumFile(f.Name, asset)); ok {
+			return Digest{Algo: algo, Hex: hex, File: f.Name, data: data}, nil, nil
+		}
+	}
+	return Digest{}, fmt.Errorf("%s not listed in the checksum files of the release", asset), nil
+}
@@ -0,0 +1,158 @@
+package github
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kavishgr/getghrel/utils"
+)
+
+// the sha256 of testdata/asset.tar.gz
+const assetSHA256 = "888b4a9d4e2aa823b133d2ea22a1d53a56e61f40b657d45c842e5ba572b70fbb"
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseChecksums(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		data   string // instead of file
+		asset  string
+		single bool
+		algo   string
+		ok     bool
+	}{
+		{name: "sha256sum, binary mode and a directory", file: "checksums.txt", asset: "asset.tar.gz", algo: "sha256", ok: true},
+		{name: "a longer name is another asset", file: "checksums.txt", asset: "asset.tar", ok: false},
+		{name: "not listed", file: "checksums.txt", asset: "missing.tar.gz", ok: false},
+		{name: "bsd", file: "SHA256SUMS.bsd", asset: "asset.tar.gz", algo: "sha256", ok: true},
+		{name: "the digest alone", file: "asset.tar.gz.sha512", asset: "asset.tar.gz", single: true, algo: "sha512", ok: true},
+		{name: "the digest alone, of a shared file", file: "asset.tar.gz.sha512", asset: "asset.tar.gz", ok: false},
+		{name: "the file of the asset names it differently", file: "asset.tar.gz.sha256", asset: "asset.tar.gz", single: true, algo: "sha256", ok: true},
+		{name: "not a digest", data: "12345  asset.tar.gz\n", asset: "asset.tar.gz", ok: false},
+		{name: "not hex", data: "zz8b4a9d4e2aa823b133d2ea22a1d53a56e61f40b657d45c842e5ba572b70fbb  asset.tar.gz\n", asset: "asset.tar.gz", ok: false},
+		{name: "upper case", data: "888B4A9D4E2AA823B133D2EA22A1D53A56E61F40B657D45C842E5BA572B70FBB  asset.tar.gz\n", asset: "asset.tar.gz", algo: "sha256", ok: true},
+		{name: "empty", data: "", asset: "asset.tar.gz", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(tt.data)
+			if tt.file != "" {
+				data = readTestdata(t, tt.file)
+			}
+			algo, hex, ok := parseChecksums(data, tt.asset, tt.single)
+			if ok != tt.ok || algo != tt.algo {
+				t.Fatalf("parseChecksums() = %q, %q, %v, want %q, %v", algo, hex, ok, tt.algo, tt.ok)
+			}
+			if ok && algo == "sha256" && hex != assetSHA256 {
+				t.Errorf("parseChecksums() = %q, want %q", hex, assetSHA256)
+			}
+		})
+	}
+}
+
+func TestChecksumFiles(t *testing.T) {
+	release := Release{Assets: []utils.Asset{
+		{Name: "asset.tar.gz"},
+		{Name: "checksums.txt"},
+		{Name: "checksums.txt.sig"},
+		{Name: "checksums.txt.pem"},
+		{Name: "asset.tar.gz.sha256"},
+		{Name: "other.tar.gz.sha256"},
+		{Name: "asset.tar.gz-arm64.sha256"},
+		{Name: "SHA256SUMS"},
+	}}
+	var got []string
+	for _, a := range checksumFiles(release, "asset.tar.gz") {
+		got = append(got, a.Name)
+	}
+	want := []string{"asset.tar.gz.sha256", "checksums.txt", "SHA256SUMS"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("checksumFiles() = %v, want %v", got, want)
+	}
+
+	// the checksum file of another asset starting like it is not its own
+	release = Release{Assets: []utils.Asset{{Name: "tool"}, {Name: "tool-arm64.sha256"}, {Name: "tool.SHA256"}}}
+	got = nil
+	for _, a := range checksumFiles(release, "tool") {
+		got = append(got, a.Name)
+	}
+	if want := []string{"tool.SHA256"}; !slices.Equal(got, want) {
+		t.Fatalf("checksumFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestFindDigest(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer srv.Close()
+	asset := func(name string) utils.Asset {
+		return utils.Asset{Name: name, URL: srv.URL + "/" + name}
+	}
+
+	tests := []struct {
+		name       string
+		assets     []string
+		file       string
+		algo       string
+		unverified bool
+		wantErr    bool
+	}{
+		{name: "the file of the asset first", assets: []string{"checksums.txt", "asset.tar.gz.sha512"}, file: "asset.tar.gz.sha512", algo: "sha512"},
+		{name: "a checksum file of the release", assets: []string{"checksums.txt"}, file: "checksums.txt", algo: "sha256"},
+		{name: "the next file when one doesn't list it", assets: []string{"tool_checksums.txt", "SHA256SUMS.bsd"}, file: "SHA256SUMS.bsd", algo: "sha256"},
+		{name: "another asset starting like it", assets: []string{"asset.tar.gz-arm64.sha256", "checksums.txt"}, file: "checksums.txt", algo: "sha256"},
+		{name: "a file that fails to download", assets: []string{"missing_checksums.txt", "checksums.txt"}, wantErr: true},
+		{name: "no checksum file", assets: []string{"asset.tar.gz"}, unverified: true},
+		{name: "not listed", assets: []string{"tool_checksums.txt"}, unverified: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var release Release
+			for _, name := range tt.assets {
+				release.Assets = append(release.Assets, asset(name))
+			}
+			d, unverified, err := findDigest("", release, "asset.tar.gz")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("findDigest() = %+v, %v, want an error", d, unverified)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.unverified {
+				if unverified == nil {
+					t.Fatalf("findDigest() = %+v, want it unverified", d)
+				}
+				return
+			}
+			if unverified != nil {
+				t.Fatal(unverified)
+			}
+			if d.File != tt.file || d.Algo != tt.algo {
+				t.Errorf("findDigest() = %s from %s, want %s from %s", d.Algo, d.File, tt.algo, tt.file)
+			}
+			h := d.newHash()
+			h.Write(readTestdata(t, "asset.tar.gz"))
+			if got := hex.EncodeToString(h.Sum(nil)); got != d.Hex {
+				t.Errorf("findDigest() = %s, the %s of the asset is %s", d.Hex, d.Algo, got)
+			}
+			if len(d.data) == 0 {
+				t.Error("findDigest() didn't keep the checksum file, its signature can't be verified")
+			}
+		})
+	}
+}
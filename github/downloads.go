@@ -0,0 +1,139 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/kavishgr/getghrel/utils"
+	"github.com/kavishgr/getghrel/verify"
+)
+
+// DownloadOptions are the settings shared by every DownloadRelease goroutine
+type DownloadOptions struct {
+	GHToken        string
+	TempDir        string
+	SkipExtraction bool
+	Platforms      []utils.Platform
+	Downloads      *Downloads
+	// "flat" extracts every file inside tempdir,
+	// "tree" keeps the directories of the archive in tempdir/<owner>_<repo>/<version>
+	Layout string
+	// limits of the extraction of each archive
+	Extract utils.ExtractOptions
+	// the size and sha256 an url must have (-locked), the assets that don't match are not extracted
+	Checksums map[string]Checksum
+	// the keys the signatures of the assets are verified with, nil to skip the verification
+	Keys *verify.Keys
+	// refuse the assets without a signature verified by Keys
+	RequireSignature bool
+	// refuse the assets without a github attestation (SLSA provenance) verified by Keys
+	Attestation bool
+}
+
+// Checksum is the size and SHA-256 (hex) of an asset
+type Checksum struct {
+	Size   int64
+	SHA256 string
+}
+
+/*
+- Download the asset at url and return its size and SHA-256,
+without saving it.
+
+- Used when the release doesn't provide the digest of its assets.
+*/
+func HashAsset(ghtoken, u string) (Checksum, error) {
+	req, err := craftDownloadReq(ghtoken, u)
+	if err != nil {
+		return Checksum{}, err
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return Checksum{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Checksum{}, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+
+	sum := sha256.New()
+	size, err := io.Copy(sum, resp.Body)
+	if err != nil {
+		return Checksum{}, err
+	}
+	return Checksum{Size: size, SHA256: hex.EncodeToString(sum.Sum(nil))}, nil
+}
+
+// Downloaded is an asset saved by DownloadRelease
+// and the files it produced once extracted
+type Downloaded struct {
+	Asset    string
+	URL      string
+	Repo     string // owner/repo
+	Tag      string
+	Platform utils.Platform
+	Files    []string
+	// with the tree layout, the directory the asset was extracted in
+	Dir string
+	// how the asset was verified, for e.g "sha256 from checksums.txt", empty if it was not
+	Verified string
+	// the signature that verified the asset, for e.g "minisign bat.tar.gz.minisig", empty if none did
+	Signed string
+	// the workflow that built the asset, going by its verified attestation, empty if it was not checked
+	Attested string
+}
+
+// Downloads records the assets saved by the DownloadRelease goroutines
+type Downloads struct {
+	mu   sync.Mutex
+	list []Downloaded
+}
+
+func (d *Downloads) Add(dl Downloaded) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.list = append(d.list, dl)
+}
+
+func (d *Downloads) List() []Downloaded {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Downloaded(nil), d.list...)
+}
+
+// Return the asset each file came from, for e.g "/tmp/getghrel/bat" -> "bat-v0.24.0-x86_64-unknown-linux-gnu.tar.gz"
+func (d *Downloads) Origins() map[string]string {
+	origins := make(map[string]string)
+	for _, dl := range d.List() {
+		for _, f := range dl.Files {
+			origins[f] = dl.Asset
+		}
+	}
+	return origins
+}
+
+/*
+- Return the owner/repo and the tag of a release asset url,
+for e.g https://github.com/sharkdp/bat/releases/download/v0.24.0/bat-v0.24.0-x86_64-unknown-linux-gnu.tar.gz
+-> "sharkdp/bat", "v0.24.0"
+
+- ok is false if the url is not a github release asset url.
+*/
+func parseReleaseUrl(u string) (repo, tag string, ok bool) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", "", false
+	}
+	// owner, repo, "releases", "download", tag, asset
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) != 6 || parts[2] != "releases" || parts[3] != "download" {
+		return "", "", false
+	}
+	return parts[0] + "/" + parts[1], parts[4], true
+}
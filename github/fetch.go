@@ -0,0 +1,351 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/k0kubun/go-ansi"
+	"github.com/schollz/progressbar/v3"
+)
+
+// how many times a download is retried, and the delay before the first retry (doubled after each one)
+const (
+	downloadRetries = 4
+	retryDelay      = time.Second
+)
+
+// the hosts the github token is sent to
+var githubHosts = []string{"github.com", "api.github.com"}
+
+// how long a download waits for a connection, a TLS handshake, the headers of the response,
+// and for the next bytes of its body before it times out (and is retried)
+const (
+	dialTimeout           = 30 * time.Second
+	tlsHandshakeTimeout   = 10 * time.Second
+	responseHeaderTimeout = 30 * time.Second
+)
+
+var idleReadTimeout = time.Minute
+
+/*
+- downloadClient follows the redirects of github to the storage of the assets
+(objects.githubusercontent.com, or S3) without the Authorization header:
+the token is only for github, and the signed url of the storage rejects it.
+
+- A connection, a TLS handshake or a response that takes too long times out,
+and so does a body that stops sending data (see idleTransport): the body
+of an asset has no overall timeout, it can take as long as it keeps coming.
+*/
+var downloadClient = &http.Client{
+	Transport: idleTransport{downloadTransport()},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !contains(githubHosts, req.URL.Hostname()) {
+			req.Header.Del("Authorization")
+		}
+		return nil
+	},
+}
+
+// Return the transport of downloadClient: the default one, with the timeouts of a download
+func downloadTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	t.TLSHandshakeTimeout = tlsHandshakeTimeout
+	t.ResponseHeaderTimeout = responseHeaderTimeout
+	return t
+}
+
+// idleTransport cancels a response whose body sends nothing for idleReadTimeout
+type idleTransport struct {
+	http.RoundTripper
+}
+
+func (t idleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.RoundTripper.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &idleBody{ReadCloser: resp.Body, cancel: cancel}
+	body.timer = time.AfterFunc(idleReadTimeout, func() {
+		body.stalled.Store(true)
+		cancel()
+	})
+	resp.Body = body
+	return resp, nil
+}
+
+// idleBody is the body of a response, canceled when it stalls for idleReadTimeout
+type idleBody struct {
+	io.ReadCloser
+	timer   *time.Timer
+	stalled atomic.Bool
+	cancel  context.CancelFunc
+}
+
+// errIdle is the error of a body that stalled, a timeout like the others
+type errIdle struct{}
+
+func (errIdle) Error() string   { return fmt.Sprintf("no data received for %s", idleReadTimeout) }
+func (errIdle) Timeout() bool   { return true }
+func (errIdle) Temporary() bool { return true }
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.stalled.Load() {
+		return n, errIdle{}
+	}
+	b.timer.Reset(idleReadTimeout)
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+/*
+- Return the request downloading the file at u.
+
+- The asset api (https://api.github.com/repos/{owner}/{repo}/releases/assets/{id})
+answers with the asset itself with Accept: application/octet-stream, instead of its json.
+The token is not sent to other hosts than github.
+*/
+func craftDownloadReq(ghtoken, u string) (*http.Request, error) {
+	req, err := craftGithubReq(ghtoken, u)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Hostname() == "api.github.com" {
+		req.Header.Set("Accept", "application/octet-stream")
+	}
+	if !contains(githubHosts, req.URL.Hostname()) {
+		req.Header.Del("Authorization")
+	}
+	return req, nil
+}
+
+// errRetry marks the errors of an attempt that may pass when retried
+type errRetry struct{ err error }
+
+func (e errRetry) Error() string { return e.err.Error() }
+func (e errRetry) Unwrap() error { return e.err }
+
+/*
+- Return err marked for a retry when it's transient: a timeout, a connection
+reset or refused, or a connection cut before the end of the body.
+
+- Any other error (a bad url, an unknown host, an invalid certificate...)
+fails the same way every time, it's returned as it is.
+*/
+func transient(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout(),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		return errRetry{err}
+	}
+	return err
+}
+
+// a download of an url into a .part file, over one or more attempts
+type partDownload struct {
+	ghtoken string
+	url     string
+	part    string
+	name    string
+	// the validator of the first response (its ETag or Last-Modified),
+	// a range request resumes only if the file didn't change since
+	validator string
+	bar       *progressbar.ProgressBar
+}
+
+/*
+- Download the file at url into dst, showing a progress bar named name,
+and return its size.
+
+- The file is written to dst.part, and renamed to dst only once it's complete:
+the status must be 200 (or 206) and the size the one the server announced.
+A failed attempt (timeout, connection reset or refused, 429 or 5xx status,
+cut connection) is retried
+up to downloadRetries times with an exponential backoff, resuming
+where it stopped with a range request when the server supports them.
+
+- A .part file left by an earlier run is not resumed, it could be another file.
+*/
+func downloadFile(ghtoken, u, dst, name string) (int64, error) {
+	d := &partDownload{ghtoken: ghtoken, url: u, part: dst + ".part", name: name}
+	os.Remove(d.part)
+	defer func() {
+		if d.bar != nil {
+			d.bar.Reset()
+			d.bar.Finish()
+			d.bar.Close()
+		}
+	}()
+
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		size, err := d.attempt()
+		if err == nil {
+			if err := os.Rename(d.part, dst); err != nil {
+				return 0, err
+			}
+			return size, nil
+		}
+		var retry errRetry
+		if !errors.As(err, &retry) || attempt == downloadRetries {
+			os.Remove(d.part)
+			return 0, err
+		}
+		if d.bar != nil {
+			d.bar.Clear()
+		}
+		fmt.Printf("Warning: %s: %v, retrying in %s\n", name, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Download the rest of the .part file, return its size once it's complete
+func (d *partDownload) attempt() (int64, error) {
+	var offset int64
+	if fi, err := os.Stat(d.part); err == nil {
+		offset = fi.Size()
+	}
+
+	req, err := craftDownloadReq(d.ghtoken, d.url)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 && d.validator != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", d.validator)
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return 0, transient(err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	var total int64
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && req.Header.Get("Range") != "":
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			os.Remove(d.part)
+			return 0, errRetry{fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))}
+		}
+		flags |= os.O_APPEND
+		total = size
+	case resp.StatusCode == http.StatusOK:
+		// the whole file, from the start
+		flags |= os.O_TRUNC
+		offset = 0
+		total = resp.ContentLength
+		d.validator = resp.Header.Get("ETag")
+		if d.validator == "" {
+			d.validator = resp.Header.Get("Last-Modified")
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		os.Remove(d.part)
+		return 0, errRetry{errors.New(resp.Status)}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return 0, errRetry{errors.New(resp.Status)}
+	default:
+		return 0, errors.New(resp.Status)
+	}
+
+	f, err := os.OpenFile(d.part, flags, 0644)
+	if err != nil {
+		return 0, err
+	}
+	if d.bar == nil {
+		d.bar = newBar(total, d.name)
+	}
+	d.bar.ChangeMax64(total)
+	d.bar.Set64(offset)
+	n, copyErr := io.Copy(io.MultiWriter(f, d.bar), resp.Body)
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	size := offset + n
+	switch {
+	case copyErr != nil:
+		return 0, transient(copyErr)
+	case total >= 0 && size < total:
+		return 0, errRetry{fmt.Errorf("got %d of %d bytes", size, total)}
+	case total >= 0 && size > total:
+		os.Remove(d.part)
+		return 0, errRetry{fmt.Errorf("got %d bytes, expected %d", size, total)}
+	}
+	return size, nil
+}
+
+// Return the progress bar of a download of total bytes (-1 when unknown)
+func newBar(total int64, name string) *progressbar.ProgressBar {
+	return progressbar.NewOptions64(total,
+		progressbar.OptionSetWriter(ansi.NewAnsiStdout()),
+		progressbar.OptionEnableColorCodes(true),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetElapsedTime(true),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionSetWidth(15),
+		progressbar.OptionSetDescription(name),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "[green]=[reset]",
+			SaucerHead:    "[green]>[reset]",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}))
+}
+
+// Parse a Content-Range header: "bytes 100-999/1000" -> 100, 1000.
+// ok is false when it's not one, or the size is unknown
+func parseContentRange(h string) (start, size int64, ok bool) {
+	r, found := strings.CutPrefix(h, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	span, total, found := strings.Cut(r, "/")
+	first, _, found2 := strings.Cut(span, "-")
+	if !found || !found2 {
+		return 0, 0, false
+	}
+	start, err1 := strconv.ParseInt(first, 10, 64)
+	size, err2 := strconv.ParseInt(total, 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
+
+// Write the content of the file at path to w
+func hashFile(path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
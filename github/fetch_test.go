@@ -0,0 +1,80 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadStalled(t *testing.T) {
+	saved := idleReadTimeout
+	idleReadTimeout = 100 * time.Millisecond
+	defer func() { idleReadTimeout = saved }()
+
+	// the first bytes of the body, then nothing until the client gives up
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		fmt.Fprint(w, "0123456789")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	d := &partDownload{url: srv.URL + "/tool.tar.gz", part: filepath.Join(t.TempDir(), "tool.tar.gz.part"), name: "tool.tar.gz"}
+	start := time.Now()
+	_, err := d.attempt()
+	var retry errRetry
+	if !errors.As(err, &retry) {
+		t.Fatalf("attempt() = %v, want a timeout that's retried", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("attempt() gave up after %s, want about %s", elapsed, idleReadTimeout)
+	}
+	// what was received is kept, to resume from
+	if fi, err := os.Stat(d.part); err != nil || fi.Size() != 10 {
+		t.Errorf("%s after the stall: %v, want the 10 bytes received", d.part, err)
+	}
+}
+
+func TestDownloadSlow(t *testing.T) {
+	saved := idleReadTimeout
+	idleReadTimeout = 200 * time.Millisecond
+	defer func() { idleReadTimeout = saved }()
+
+	// a body slower than the idle timeout overall, but never idle for that long
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		for i := 0; i < 5; i++ {
+			fmt.Fprint(w, i)
+			w.(http.Flusher).Flush()
+			time.Sleep(80 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "tool")
+	if size, err := downloadFile("", srv.URL+"/tool", dst, "tool"); err != nil || size != 5 {
+		t.Fatalf("downloadFile() = %d, %v, want 5 bytes", size, err)
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != "01234" {
+		t.Errorf("downloadFile() saved %q, %v, want %q", data, err, "01234")
+	}
+}
+
+func TestDownloadTransport(t *testing.T) {
+	tr := downloadTransport()
+	if tr.TLSHandshakeTimeout != tlsHandshakeTimeout || tr.ResponseHeaderTimeout != responseHeaderTimeout || tr.DialContext == nil {
+		t.Errorf("downloadTransport() has no timeouts: %+v", tr)
+	}
+	if tr.Proxy == nil {
+		t.Error("downloadTransport() ignores the proxy of the environment")
+	}
+}
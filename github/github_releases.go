@@ -1,17 +1,15 @@
 package github
 
 import (
-	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"github.com/dlclark/regexp2"
-	"github.com/k0kubun/go-ansi"
 	"github.com/kavishgr/getghrel/utils"
-	"github.com/schollz/progressbar/v3"
-	"github.com/shurcooL/githubv4"
+	"github.com/kavishgr/getghrel/verify"
 	"github.com/tidwall/gjson"
-	"golang.org/x/oauth2"
+	"hash"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -57,7 +55,11 @@ func isValidURL(url string) bool {
 func fixUrl(githubUrl string) (string, string) {
 	apiDomain := "https://api.github.com/repos"
 	apiDomainSuffix := "/releases/latest"
-	u, _ := url.Parse(githubUrl)
+	u, err := url.Parse(githubUrl)
+	if err != nil {
+		// not an url, the request built from it reports the error
+		u = &url.URL{Path: githubUrl}
+	}
 	fortag := fmt.Sprintf("%s", u.Path)
 
 	if isValidURL(githubUrl) {
@@ -73,16 +75,18 @@ func fixUrl(githubUrl string) (string, string) {
 - Creates an authenticated HTTP GET request for the GitHub API
 by setting the required headers,
 including the GitHub token and user agent
+
+- An error is returned when url is not a valid one
 */
-func craftGithubReq(ghtoken, url string) *http.Request {
+func craftGithubReq(ghtoken, url string) (*http.Request, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	req.Header.Add("Authorization", fmt.Sprintf("token %s", ghtoken))
 	// req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
 	req.Header.Add("User-Agent", "getghrel-cli")
-	return req
+	return req, nil
 }
 
 /*
@@ -92,66 +96,186 @@ func craftGithubReq(ghtoken, url string) *http.Request {
   - It uses a GitHub token for authentication
     saves the downloaded files to a temporary directory
     and optionally extracts the files if specified.
+
+  - A release asset is downloaded through the asset api (its APIURL),
+    so the urls printed by -list work for private repos too.
+
+  - Each asset is downloaded with downloadFile: a failed download is retried,
+    resumed where it stopped, and reported without stopping the other downloads.
+    Only a complete asset is saved, and it's hashed once complete.
+
+  - With multiple platforms, each asset is saved in the directory
+    of the platform it was built for (see utils.PlatformDir).
+
+  - Every asset and the files extracted from it are recorded in opts.Downloads,
+    so cleanup can tell which asset a binary came from.
+
+  - With the "tree" layout, each asset is extracted in its own
+    <dir>/<owner>_<repo>/<version> directory, keeping the directories of the archive.
+
+  - The digest of each asset is looked up in the checksum files of its release
+    (see findDigest) and checked before the extraction: an asset that doesn't match
+    is removed and not extracted. Assets without a checksum file are reported as unverified,
+    and an asset whose checksum file fails to download is not downloaded.
+
+  - With opts.Keys, the signature of each asset (or of the checksum file that verified it)
+    is verified with the keys trusted for its repo (see verifySignature): an asset whose
+    signature doesn't verify is removed, and so is an unsigned one with opts.RequireSignature.
+
+  - With opts.Attestation, the github attestation of each asset must verify (see verifyAttestation):
+    an asset without one, or built from another repo or by another workflow, is removed.
 */
-func DownloadRelease(urlsChan chan string, job *sync.WaitGroup, ghtoken, tempdir string, skipextraction bool) {
+func DownloadRelease(urlsChan chan string, job *sync.WaitGroup, opts DownloadOptions) {
 
 	defer job.Done()
 
+	ghtoken, tempdir, skipextraction, platforms := opts.GHToken, opts.TempDir, opts.SkipExtraction, opts.Platforms
+
 	// anonymous func() to handle file download and processing
 	// so that defer() gets called upon each iteration
 	// instead of waiting for DownloadRelease() to return
 	downloadAndProcessFile := func(u string) {
 		// get the assetname of each url -> e.g bat.tar.gz
 		file := path.Base(u)
-		src := filepath.Join(tempdir, file)
 
-		req := craftGithubReq(ghtoken, u)
-		client := http.Client{}
-		resp, err := client.Do(req)
+		// with multiple targets, each one has its own directory
+		platform := utils.MatchPlatform(file, platforms)
+		dir := utils.PlatformDir(tempdir, platform, platforms)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Printf("Error: %s: %v\n", file, err)
+			return
+		}
+		src := filepath.Join(dir, file)
+
+		downloaded := Downloaded{Asset: file, URL: u, Platform: platform, Files: []string{src}}
+		downloaded.Repo, downloaded.Tag, _ = parseReleaseUrl(u)
+
+		// the digest of the asset in the checksum file of its release, if there's one
+		var release Release
+		digest, unverified := Digest{}, errors.New("not a github release asset")
+		if downloaded.Repo != "" {
+			if release, unverified = fetchRelease(ghtoken, downloaded.Repo, downloaded.Tag); unverified == nil {
+				var err error
+				if digest, unverified, err = findDigest(ghtoken, release, file); err != nil {
+					fmt.Printf("Error: %s: %v\n", file, err)
+					return
+				}
+			}
+		}
+
+		// through the asset api when it's a release asset, browser urls don't work for private repos
+		fetchURL := u
+		if a, ok := release.assetNamed(file); ok {
+			fetchURL = a.DownloadURL()
+		}
+
+		size, err := downloadFile(ghtoken, fetchURL, src, file)
 		if err != nil {
-			log.Fatal(err)
+			fmt.Printf("Error: %s: %v\n", file, err)
+			return
+		}
+
+		// hashed once complete, a download can be resumed
+		sum := sha256.New()
+		writers := []io.Writer{sum}
+		var digestSum hash.Hash
+		if unverified == nil {
+			digestSum = digest.newHash()
+			writers = append(writers, digestSum)
+		}
+		if err := hashFile(src, io.MultiWriter(writers...)); err != nil {
+			fmt.Printf("Error: %s: %v\n", file, err)
+			return
+		}
+
+		// an asset that's not the one its checksum file lists is not extracted
+		if unverified == nil {
+			if got := hex.EncodeToString(digestSum.Sum(nil)); got != digest.Hex {
+				os.Remove(src)
+				fmt.Printf("Error: %s: %s mismatch, %s says %s, got %s\n", file, digest.Algo, digest.File, digest.Hex, got)
+				return
+			}
+			downloaded.Verified = digest.Algo + " from " + digest.File
+		}
+		status := "verified: " + downloaded.Verified
+		if unverified != nil {
+			status = "unverified: " + unverified.Error()
+		}
+
+		// with -locked, an asset that's not the one in the lockfile is not extracted
+		if want, ok := opts.Checksums[u]; ok {
+			got := Checksum{Size: size, SHA256: hex.EncodeToString(sum.Sum(nil))}
+			if got != want {
+				os.Remove(src)
+				fmt.Printf("Error: %s: got %d bytes with sha256 %s, the lockfile expects %d bytes with sha256 %s\n",
+					file, got.Size, got.SHA256, want.Size, want.SHA256)
+				return
+			}
+		}
+
+		// with trusted keys, an asset whose signature doesn't verify is not extracted
+		if opts.Keys != nil {
+			var verifiedBy *Digest
+			if unverified == nil {
+				verifiedBy = &digest
+			}
+			signature, err := checkSignature(ghtoken, opts, release, src, &downloaded, verifiedBy)
+			if err != nil {
+				os.Remove(src)
+				fmt.Printf("Error: %s: %v\n", file, err)
+				return
+			}
+			status += ", " + signature
+		}
+
+		// with -attestation, an asset not built by its own repo is not extracted
+		if opts.Attestation {
+			if downloaded.Repo == "" {
+				err = errors.New("not a github release asset")
+			} else {
+				var p verify.Provenance
+				if p, err = verifyAttestation(ghtoken, opts.Keys, downloaded.Repo, hex.EncodeToString(sum.Sum(nil))); err == nil {
+					downloaded.Attested = p.Workflow
+				}
+			}
+			if err != nil {
+				os.Remove(src)
+				fmt.Printf("Error: %s: not attested: %v\n", file, err)
+				return
+			}
+			status += ", attested: " + downloaded.Attested
 		}
-		defer resp.Body.Close()
-
-		f, _ := os.OpenFile(src, os.O_CREATE|os.O_WRONLY, 0644)
-		defer f.Close()
-
-		// bar := progressbar.DefaultBytes(
-		// 	resp.ContentLength,
-		// 	file,
-		// )
-
-		// io.Copy(io.MultiWriter(f, bar), resp.Body)
-
-		bar := progressbar.NewOptions64(resp.ContentLength,
-			progressbar.OptionSetWriter(ansi.NewAnsiStdout()),
-			progressbar.OptionEnableColorCodes(true),
-			progressbar.OptionClearOnFinish(),
-			progressbar.OptionSetElapsedTime(true),
-			progressbar.OptionShowBytes(true),
-			progressbar.OptionSetWidth(15),
-			progressbar.OptionSetDescription(fmt.Sprintf("%s", file)),
-			progressbar.OptionSetTheme(progressbar.Theme{
-				Saucer:        "[green]=[reset]",
-				SaucerHead:    "[green]>[reset]",
-				SaucerPadding: " ",
-				BarStart:      "[",
-				BarEnd:        "]",
-			}))
-
-		io.Copy(io.MultiWriter(f, bar), resp.Body)
-		bar.Reset()
-		bar.Finish()
 
 		if skipextraction {
-			fmt.Printf("Downloaded: %s\n", file)
-			bar.Close()
+			fmt.Printf("Downloaded: %s (%s)\n", file, status)
+			opts.Downloads.Add(downloaded)
 			return
 		}
 
-		fmt.Printf("Downloaded and Extracted: %s\n", file)
-		bar.Close()
-		utils.Extractor(src, tempdir)
+		if opts.Layout == "tree" {
+			downloaded.Dir = treeDir(dir, downloaded)
+			files, err := utils.ExtractTree(src, downloaded.Dir, opts.Extract)
+			// the archive is not needed anymore
+			os.Remove(src)
+			if err != nil {
+				fmt.Printf("Error: %s: %v\n", file, err)
+				return
+			}
+			fmt.Printf("Downloaded and Extracted: %s (%s)\n", file, status)
+			downloaded.Files = files
+			opts.Downloads.Add(downloaded)
+			return
+		}
+
+		files, err := utils.Extractor(src, dir, opts.Extract)
+		if err != nil {
+			// the archive itself is removed by cleanup
+			fmt.Printf("Error: %s: %v\n", file, err)
+			return
+		}
+		fmt.Printf("Downloaded and Extracted: %s (%s)\n", file, status)
+		downloaded.Files = append(downloaded.Files, files...)
+		opts.Downloads.Add(downloaded)
 	}
 
 	// iterate over urls sent by stdin
@@ -160,248 +284,229 @@ func DownloadRelease(urlsChan chan string, job *sync.WaitGroup, ghtoken, tempdir
 	}
 }
 
-/*
-- Takes a string in the format "owner/repo" as input
-and returns two strings.
-
-- It extracts the owner and repository names
-from the input string by splitting it at the '/' character.
-
-- If the input starts with a '/',
-it removes it before performing the split.
-
-- The function then returns the extracted owner
-and repository names as separate strings.
-*/
-func split(ownerNrepo string) (string, string) {
-	var str string
-	if strings.HasPrefix(ownerNrepo, "/") {
-		str = strings.TrimPrefix(ownerNrepo, "/")
+// Return the directory of an asset with the tree layout: <dir>/<owner>_<repo>/<version>,
+// so a/tool and b/tool don't share one. Assets that don't come from a github release
+// use their name instead. <dir>/bin is where the executables are exposed, an asset named bin gets <dir>/_bin
+func treeDir(dir string, dl Downloaded) string {
+	reserve := func(name string) string {
+		if strings.EqualFold(name, "bin") {
+			return "_" + name
+		}
+		return name
+	}
+	if dl.Repo == "" {
+		name := strings.TrimSuffix(dl.Asset, filepath.Ext(dl.Asset))
+		return filepath.Join(dir, reserve(strings.TrimSuffix(name, ".tar")))
 	}
-	parts := strings.Split(str, "/")
-	return parts[0], parts[1]
+	return filepath.Join(dir, strings.ReplaceAll(dl.Repo, "/", "_"), dl.Tag)
 }
 
 /*
-- Retrieves information about a GitHub repository's latest release tag
-using the GitHub GraphQL API.
-It takes a GitHub API token (`ghtoken`)
-and a string in the format "owner/repo" (`ownerNrepo`)
-representing the repository's owner and name.
-
-- The function uses the `split` function to separate
-the owner and repository names from the input string.
-It then sets up an OAuth2 token source
-and an HTTP client to create a GitHub GraphQL client.
-
-- Next, the function defines a GraphQL query to fetch
-the latest release tag for the given repository.
-The query specifies the required fields,
-such as the name of the tag and sorting based on commit date.
-
-- After executing the GraphQL query,
-the function extracts the latest tag name
-from the query result.
-It constructs the URL for the GitHub API
-endpoint related to the retrieved tag.
-
-- Using the `craftGithubReq` function,
-it creates an HTTP GET request
-with the provided GitHub token and tag URL.
-The function then sends the request,
-reads the response body,
-and returns it as a byte slice containing
-the information about the latest release tag.
-*/
-func getTagByName(ghtoken, ownerNrepo string) []byte {
-	// GitHub API token
-
-	var tagname string
-	owner, name := split(ownerNrepo) // owner and name of the repo
-
-	// Create an OAuth2 token source
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: ghtoken},
-	)
-
-	// Create an HTTP client with the token source
-	oauthClient := oauth2.NewClient(context.Background(), src)
-
-	// Create a new GitHub GraphQL client
-	gqlClient := githubv4.NewClient(oauthClient)
-
-	// Define the GraphQL query
-	var query struct {
-		Repository struct {
-			Refs struct {
-				Edges []struct {
-					Node struct {
-						Name string
-					}
-				}
-			} `graphql:"refs(refPrefix: $refPrefix, first: $first, orderBy: $orderBy)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
+- Fetch the latest release of a github url or username/repo that the policy allows.
 
-	// Set the query variables
-	variables := map[string]interface{}{
-		"owner":     githubv4.String(owner),
-		"name":      githubv4.String(name),
-		"refPrefix": githubv4.String("refs/tags/"),
-		"first":     githubv4.Int(1),
-		"orderBy": githubv4.RefOrder{
-			Field:     githubv4.RefOrderFieldTagCommitDate,
-			Direction: githubv4.OrderDirectionDesc,
-		},
-	}
+- With the stable-only policy, it's the release github marks as the latest one
+(never a prerelease), unless its tag says it's a prerelease or a nightly build.
+Otherwise, and for the other policies, it's the most recent release the policy
+allows among the last releasesPerPage releases.
 
-	// Execute the GraphQL query
-	err := gqlClient.Query(context.Background(), &query, variables)
-	if err != nil {
-		log.Fatal(err)
-	}
+- An empty release is returned when there's none,
+and an error when the github API can't be reached.
+*/
+func latestRelease(ghtoken, u string, policy Policy) (Release, error) {
+	githubUrl, ownerNrepo := fixUrl(u) // fix url and return valid api url
 
-	// Access the query result
-	for _, edge := range query.Repository.Refs.Edges {
-		// fmt.Println("Tag Name:", edge.Node.Name)
-		tagname = edge.Node.Name
-		// fmt.Println(tagname)
+	if policy == StableOnly || policy == "" {
+		body, status, err := getAPI(ghtoken, githubUrl)
+		if err != nil {
+			return Release{}, err
+		}
+		if status == http.StatusOK {
+			if release := parseRelease(body); policy.Allows(release) {
+				return release, nil
+			}
+		}
 	}
 
-	tagUrl := fmt.Sprintf("https://api.github.com/repos%s/releases/tags/%s", ownerNrepo, tagname)
-	// fmt.Println(tagUrl)
-	// fmt.Println("TAGURL:", tagUrl)
-
-	req := craftGithubReq(ghtoken, tagUrl)
-	client := http.Client{}
-	resp, err := client.Do(req)
+	// "Not Found" when the repo only has prereleases (or no release at all)
+	releases, err := listReleases(ghtoken, strings.Trim(ownerNrepo, "/"), 1)
 	if err != nil {
-		log.Fatal(err)
+		return Release{}, err
 	}
-	body, err := io.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if err != nil {
-		log.Fatal(err)
+	for _, r := range releases {
+		if policy.Allows(r) {
+			return r, nil
+		}
 	}
-	return body
-}
-
-// verify if a map is empty or not
-func mapIsEmpty(m map[string]int) bool {
-	return len(m) == 0 // returns true if map is empty
+	return Release{}, nil
 }
 
 /* - fetch the asset urls from latest release for each url or username/repo (used by -list)
-   - the regex is used to find the required asset url for your os/arch
-   - return/print found urls for each asset that matched
+   - every asset is scored for your os/arch and the best one is printed
+   - when no asset names both the os and the arch, an asset naming only the os
+     is printed with a "# low confidence" comment
+   - with -rank, every asset is printed with its score and the reasons behind it
    - repos that do not have a release for the os and arch will be printed like so:
    - N/A: https://github.com/user/repo
 */
 
 /*
 - Fetches the download URLs for specific assets from GitHub releases.
-It uses a regular expression (regex) to filter URLs
-based on the target OS/architecture.
+It scores every asset of the release for the target OS/architecture
+and keeps the best one.
 The function takes URLs from the urlsChan channel
 and uses a provided GitHub API token (ghtoken)
 to make API requests to fetch release information.
 
 - The function starts by defining an inner function fetch responsible
 for handling the URL processing.
-Within this function, it prepares the API URL
-using fixUrl and constructs an HTTP GET request
-with the provided GitHub token using craftGithubReq.
-It then sends the request and reads the response body
-containing release information.
-
-- The function checks if the response contains a "Not Found" message,
-indicating that the repository may be using tags
-instead of the latest release. In such cases,
-it fetches the assets for the most recent tag
-using the getTagByName function.
+Within this function, it fetches the release information
+with latestRelease, which prepares the API URL
+using fixUrl and sends a request built by craftGithubReq.
 
-- Next, the function uses gjson to parse the response body
-and extract the URLs of the assets.
-It applies the provided regular expression
-to filter the URLs based on the target OS/architecture.
-URLs that match the regex are stored in the github_release map.
+- Only the releases the policy allows are picked:
+by default, a repo without a latest release, or whose latest release
+is tagged like a prerelease, gets its most recent stable release instead.
+A line like "owner/repo@nightly" picks a release with the nightly policy.
+
+- A line like "owner/repo@^1.4" (or "owner/repo@v1.4.2") is resolved
+with ResolveRelease instead: the release with that tag, or the highest
+release satisfying the semver constraint. The tag it resolved to
+is printed as a comment.
 
-- If there are matching URLs, they are printed to the console.
-If there are no matching URLs, "N/A" is printed to
+- Next, the function uses gjson to parse the response body
+and extract the name and URL of the assets.
+Every asset is ranked with utils.RankAssets,
+and the best one that matches both the OS and the architecture is printed.
+If there is none, the best asset that names only the OS
+is printed with a low confidence comment.
+
+- With multiple platforms (-target), the best asset of each platform
+is printed, followed by the platform as a comment.
+
+- If ranked is set, every asset is printed with its score
+and the reasons for it, best first.
+If there are no matching assets, "N/A" is printed to
 indicate that no relevant assets were found.
 
 - The main loop of the function continuously receives URLs
 from urlsChan and processes them using the fetch function.
 */
-func FetchGithubReleaseUrl(urlsChan chan string, job *sync.WaitGroup, regex, ghtoken string) {
+func FetchGithubReleaseUrl(urlsChan chan string, job *sync.WaitGroup, platforms []utils.Platform, ghtoken string, ranked bool, policy Policy) {
 
 	defer job.Done()
-	// var github_release []string
-	// github_release := make(map[string]int)
-
-	fetch := func(u string) {
-		github_release := make(map[string]int)
-		// fmt.Println(u)
-		// map to keep assets
-		// sometimes there are multiple assets for same os/architecture
-		// for e.g gnu and musl for linux
-		re2 := regexp2.MustCompile(regex, 0) // regex for os/arch
-		// fmt.Println("Regex: ", re)
-		githubUrl, ownerNrepo := fixUrl(u) // fix url and return valid api url
-		// fmt.Println(githubUrl)
-
-		// HTTP client starts
-		req := craftGithubReq(ghtoken, githubUrl) // craft request with token and valid api url
-		client := http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Fatal(err)
+
+	fetch := func(line string) {
+		// owner/repo@^1.4: the highest release satisfying the constraint
+		u, version, _ := strings.Cut(line, "@")
+		var release Release
+		var err error
+		if version == "" {
+			release, err = latestRelease(ghtoken, u, policy)
+		} else {
+			release, err = ResolveRelease(ghtoken, repoPath(u), version, policy)
 		}
-		body, err := io.ReadAll(resp.Body)
-		defer resp.Body.Close()
 		if err != nil {
-			log.Fatal(err)
-		}
-		// HTTP client ends
-
-		message := gjson.Get(fmt.Sprintf("%s", body), "message")
-		// if the message is "Not Found"
-		// release/asset section is EMPTY or is using tags instead of latest release
-		if message.Str == "Not Found" {
-			// fetch assets for most recent tag
-			body = getTagByName(ghtoken, ownerNrepo)
+			printAsset("N/A: "+line, err.Error())
+			return
 		}
 
-		// fetch all the browser_download_url keys which contains the asset urls
-		// results := gjson.Get(fmt.Sprintf("%s", body), "assets.#.browser_download_url")
-		results := gjson.Get(fmt.Sprintf("%s", body), "assets.#.browser_download_url")
-
-		// fmt.Println("Matching values for URL:", u)
-		results.ForEach(func(key, value gjson.Result) bool {
-			// fmt.Println(key.String())
-			asset_url := value.String()
-			// fmt.Println(asset_url)
-			isMatch, _ := re2.MatchString(asset_url)
+		for _, p := range platforms {
+			// with a single platform, the output is the same as before -target
+			target := ""
+			if len(platforms) > 1 {
+				target = p.String()
+			}
+			// report the tag the constraint resolved to
+			if version != "" {
+				target = strings.TrimSpace(target + " " + version + " -> " + release.Tag)
+			}
 
-			if isMatch == true {
-				github_release[asset_url] = 1
+			candidates := utils.RankAssets(release.Assets, p)
+			if ranked {
+				printRanked(strings.TrimSpace(line+" "+target), candidates)
+				continue
 			}
 
-			return true // keep iterating in case there are multiple urls that match
-		})
+			best, found := utils.BestAsset(candidates)
+			if !found {
+				printAsset("N/A: "+line, target)
+				continue
+			}
 
-		if mapIsEmpty(github_release) {
-			fmt.Println("N/A:", u)
-		} else {
-			for k, _ := range github_release {
-				// fmt.Println("URL found:", k)
-				fmt.Println(k)
+			// the comment is ignored by -download
+			if reason := best.LowConfidence(); reason != "" {
+				target = strings.TrimSpace(target + " low confidence: " + reason)
 			}
+			printAsset(best.URL, target)
 		}
-
 	}
 
 	for u := range urlsChan {
 		fetch(u)
 	}
 }
+
+// Return the owner/repo of a github url or of owner/repo itself
+func repoPath(u string) string {
+	_, ownerNrepo := fixUrl(u)
+	return strings.Trim(ownerNrepo, "/")
+}
+
+// print an asset url, followed by a comment if there's one
+func printAsset(url, comment string) {
+	if comment == "" {
+		fmt.Println(url)
+		return
+	}
+	fmt.Printf("%s # %s\n", url, comment)
+}
+
+// extract the name and download url of every asset in a release
+func releaseAssets(body []byte) []utils.Asset {
+	var assets []utils.Asset
+	gjson.GetBytes(body, "assets").ForEach(func(key, value gjson.Result) bool {
+		digest, _ := strings.CutPrefix(value.Get("digest").String(), "sha256:")
+		assets = append(assets, utils.Asset{
+			Name:   value.Get("name").String(),
+			URL:    value.Get("browser_download_url").String(),
+			Size:   value.Get("size").Int(),
+			SHA256: digest,
+			APIURL: value.Get("url").String(),
+		})
+		return true // keep iterating over every asset
+	})
+	return assets
+}
+
+/*
+- Print every asset of a release with its score and the reasons behind it.
+The output of a repo is printed at once,
+so it doesn't get mixed with the output of other goroutines.
+*/
+func printRanked(u string, candidates []utils.Candidate) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", u)
+
+	if len(candidates) == 0 {
+		fmt.Fprintf(&b, "  no assets found\n")
+	}
+
+	best, found := utils.BestAsset(candidates)
+	for _, c := range candidates {
+		score := fmt.Sprintf("%4d", c.Score)
+		if c.Rejected {
+			score = "   -"
+		}
+		mark := " "
+		if found && c.URL == best.URL {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s%s  %s\n", mark, score, c.URL)
+		reasons := strings.Join(c.Reasons, ", ")
+		if mark == "*" && c.LowConfidence() != "" {
+			reasons += ", low confidence: " + c.LowConfidence()
+		}
+		fmt.Fprintf(&b, "        %s\n", reasons)
+	}
+	fmt.Print(b.String())
+}
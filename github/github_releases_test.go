@@ -0,0 +1,27 @@
+package github
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTreeDir(t *testing.T) {
+	tests := []struct {
+		dl   Downloaded
+		want string
+	}{
+		{dl: Downloaded{Repo: "helix-editor/helix", Tag: "24.03", Asset: "helix-24.03-x86_64-linux.tar.xz"}, want: "helix-editor_helix/24.03"},
+		// the same repo name from two owners
+		{dl: Downloaded{Repo: "a/tool", Tag: "v1.0.0", Asset: "tool.tar.gz"}, want: "a_tool/v1.0.0"},
+		{dl: Downloaded{Repo: "b/tool", Tag: "v1.0.0", Asset: "tool.tar.gz"}, want: "b_tool/v1.0.0"},
+		{dl: Downloaded{Repo: "owner/bin", Tag: "v1", Asset: "bin.tar.gz"}, want: "owner_bin/v1"},
+		// not from a github release: its name, never the bin directory
+		{dl: Downloaded{Asset: "tool-1.0.tar.gz"}, want: "tool-1.0"},
+		{dl: Downloaded{Asset: "bin.tar.gz"}, want: "_bin"},
+	}
+	for _, tt := range tests {
+		if got := treeDir("/tmp/getghrel", tt.dl); got != filepath.Join("/tmp/getghrel", tt.want) {
+			t.Errorf("treeDir(%+v) = %s, want %s", tt.dl, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Policy decides which releases of a repo can be picked
+type Policy string
+
+const (
+	// releases that are neither prereleases nor nightly builds (the default)
+	StableOnly Policy = "stable-only"
+	// prereleases as well (rc, beta, alpha...), but not nightly builds
+	AllowPrerelease Policy = "allow-prerelease"
+	// any release, nightly builds included
+	Nightly Policy = "nightly"
+)
+
+// Policies are the supported release policies, the default first
+var Policies = []Policy{StableOnly, AllowPrerelease, Nightly}
+
+// words of the tags of nightly builds, like "nightly" or "v0.10.0-dev"
+var nightlyWords = []string{"nightly", "canary", "edge", "snapshot", "tip", "unstable", "dev", "daily"}
+
+// words of the tags of prereleases, like "v1.2.0-rc1"
+var prereleaseWords = []string{"alpha", "beta", "rc", "pre", "preview"}
+
+// Parse a release policy, the default one when s is empty
+func ParsePolicy(s string) (Policy, error) {
+	if s == "" {
+		return StableOnly, nil
+	}
+	for _, p := range Policies {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	names := make([]string, len(Policies))
+	for i, p := range Policies {
+		names[i] = string(p)
+	}
+	return "", fmt.Errorf("release policy %q is not supported, use: %s", s, strings.Join(names, ", "))
+}
+
+// Return true if one of the words (letters only) of a tag is in words:
+// "v1.2.0-rc1" is made of "v" and "rc"
+func tagHasWord(tag string, words []string) bool {
+	for _, w := range strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if contains(words, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Return true if the release is a nightly build, going by its tag
+func (r Release) IsNightly() bool {
+	return tagHasWord(r.Tag, nightlyWords)
+}
+
+// Return true if the release is a prerelease: flagged as one on github,
+// or tagged like one (a nightly build is a prerelease too)
+func (r Release) IsPrerelease() bool {
+	return r.Prerelease || r.IsNightly() || tagHasWord(r.Tag, prereleaseWords)
+}
+
+// Return true if the policy allows the release to be picked
+func (p Policy) Allows(r Release) bool {
+	switch p {
+	case Nightly:
+		return true
+	case AllowPrerelease:
+		return !r.IsNightly()
+	}
+	return !r.IsPrerelease()
+}
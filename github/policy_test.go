@@ -0,0 +1,62 @@
+package github
+
+import "testing"
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Policy
+		wantErr bool
+	}{
+		{s: "", want: StableOnly},
+		{s: "stable-only", want: StableOnly},
+		{s: "allow-prerelease", want: AllowPrerelease},
+		{s: "nightly", want: Nightly},
+		{s: "stable", wantErr: true},
+		{s: "Nightly", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %q, %v, want %q", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestPolicyAllows(t *testing.T) {
+	tests := []struct {
+		tag        string
+		prerelease bool // flagged as one on github
+		stable     bool // allowed by StableOnly
+		pre        bool // allowed by AllowPrerelease
+	}{
+		{tag: "v1.2.3", stable: true, pre: true},
+		{tag: "1.2.3", stable: true, pre: true},
+		{tag: "v1.2.3", prerelease: true, pre: true},
+		{tag: "v1.2.0-rc1", pre: true},
+		{tag: "v1.2.0-rc.1", pre: true},
+		{tag: "v2.0.0-beta.3", pre: true},
+		{tag: "v2.0.0-alpha", pre: true},
+		{tag: "v2.0.0-preview2", pre: true},
+		{tag: "nightly"},
+		{tag: "v0.10.0-dev"},
+		{tag: "canary-20240101"},
+		{tag: "v1.0.0-SNAPSHOT"},
+		// words that only contain a prerelease or a nightly word
+		{tag: "release-2024", stable: true, pre: true},
+		{tag: "v1.2.3-preset", stable: true, pre: true},
+		{tag: "devtools-v1", stable: true, pre: true},
+	}
+	for _, tt := range tests {
+		r := Release{Tag: tt.tag, Prerelease: tt.prerelease}
+		if got := StableOnly.Allows(r); got != tt.stable {
+			t.Errorf("StableOnly.Allows(%q) = %v, want %v", tt.tag, got, tt.stable)
+		}
+		if got := AllowPrerelease.Allows(r); got != tt.pre {
+			t.Errorf("AllowPrerelease.Allows(%q) = %v, want %v", tt.tag, got, tt.pre)
+		}
+		if !Nightly.Allows(r) {
+			t.Errorf("Nightly.Allows(%q) = false, want true", tt.tag)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package github
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/kavishgr/getghrel/utils"
+	"github.com/tidwall/gjson"
+)
+
+// Release is a release of a repo: its tag and its assets
+type Release struct {
+	Tag    string
+	Assets []utils.Asset
+	// flagged as a prerelease on github
+	Prerelease bool
+}
+
+// parse a release returned by the github API
+func parseRelease(body []byte) Release {
+	return Release{
+		Tag:        gjson.GetBytes(body, "tag_name").String(),
+		Assets:     releaseAssets(body),
+		Prerelease: gjson.GetBytes(body, "prerelease").Bool(),
+	}
+}
+
+// Send a request to the github API and return the body and the status code of the response
+func getAPI(ghtoken, u string) ([]byte, int, error) {
+	req, err := craftGithubReq(ghtoken, u)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return body, resp.StatusCode, err
+}
+
+// Return the release of repo (owner/repo) with the tag, found is false if there is none
+func ReleaseByTag(ghtoken, repo, tag string) (release Release, found bool, err error) {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", repo, url.PathEscape(tag))
+	body, status, err := getAPI(ghtoken, u)
+	switch {
+	case err != nil:
+		return Release{}, false, err
+	case status == http.StatusNotFound:
+		return Release{}, false, nil
+	case status != http.StatusOK:
+		return Release{}, false, fmt.Errorf("%s: %s", u, gjson.GetBytes(body, "message").String())
+	}
+	return parseRelease(body), true, nil
+}
+
+// how many releases are fetched per page, and how many pages at most
+const (
+	releasesPerPage = 100
+	maxReleasePages = 10
+)
+
+// Return the releases of repo (owner/repo), most recent first, drafts excluded.
+// Only the first pages pages are fetched
+func listReleases(ghtoken, repo string, pages int) ([]Release, error) {
+	var releases []Release
+	for page := 1; page <= pages; page++ {
+		u := fmt.Sprintf("https://api.github.com/repos/%s/releases?per_page=%d&page=%d", repo, releasesPerPage, page)
+		body, status, err := getAPI(ghtoken, u)
+		if err != nil {
+			return nil, err
+		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s", u, gjson.GetBytes(body, "message").String())
+		}
+
+		results := gjson.ParseBytes(body).Array()
+		for _, value := range results {
+			if !value.Get("draft").Bool() {
+				releases = append(releases, parseRelease([]byte(value.Raw)))
+			}
+		}
+		if len(results) < releasesPerPage {
+			break
+		}
+	}
+	return releases, nil
+}
+
+// a version of a constraint with a prerelease, like the 1.2.0-rc.1 of "^1.2.0-rc.1"
+// (and not the hyphen range "1.2 - 1.4")
+var prereleaseConstraint = regexp.MustCompile(`\d-[0-9A-Za-z]`)
+
+// characters a version can follow in a tag, for e.g the "-" of "cli-v1.2.3"
+const tagSeparators = "-_/@"
+
+/*
+- Parse the version of a tag, tolerating a "v" and tool-specific prefixes:
+"v1.2.3", "1.2.3", "cli-v1.2.3" and "jq-1.7.1" are all versions.
+
+- The prefix is returned as well ("cli-" for "cli-v1.2.3", "" for "v1.2.3"),
+ok is false if the tag is not a version. A number that is not a version,
+or only a major one like the year of "release-2024-v1.2.3", is skipped
+for a later version with a minor one.
+*/
+func parseTag(tag string) (prefix string, version *semver.Version, ok bool) {
+	for i := 0; i < len(tag); i++ {
+		if tag[i] < '0' || tag[i] > '9' {
+			continue
+		}
+		start, end := i, i
+		if i > 0 && (tag[i-1] == 'v' || tag[i-1] == 'V') {
+			start = i - 1
+		}
+		for end < len(tag) && tag[end] >= '0' && tag[end] <= '9' {
+			end++
+		}
+		if start > 0 && !strings.ContainsRune(tagSeparators, rune(tag[start-1])) {
+			i = end
+			continue
+		}
+		v, err := semver.NewVersion(tag[i:])
+		switch {
+		case err != nil:
+		case end < len(tag) && tag[end] == '.':
+			return tag[:start], v, true
+		case !ok:
+			prefix, version, ok = tag[:start], v, true
+		}
+		i = end
+	}
+	return prefix, version, ok
+}
+
+/*
+- Return true if tag is a newer version than current, going by semver (see parseTag).
+
+- A tag that is not a version, or the version of another tool
+(another prefix, like "sdk-v2.0.0" for "cli-v1.2.3"), is never newer.
+*/
+func NewerTag(tag, current string) bool {
+	prefix, v, ok := parseTag(tag)
+	currentPrefix, cv, currentOk := parseTag(current)
+	if !ok || !currentOk || !strings.EqualFold(prefix, currentPrefix) {
+		return false
+	}
+	return v.GreaterThan(cv)
+}
+
+/*
+- Split a version constraint into the prefix of the tags it's about
+and the constraint itself: "cli-^1.2" -> "cli-", "^1.2" and "v1.4.x" -> "", "1.4.x".
+
+- In a repo releasing several tools (with tags like "cli-v1.2.3" and "sdk-v2.0.0"),
+the prefix picks the tool. A constraint without a prefix matches any tag.
+*/
+func splitConstraint(version string) (prefix, constraint string) {
+	i := strings.IndexAny(version, "0123456789^~<>=!*")
+	if i == -1 {
+		return version, ""
+	}
+	prefix = strings.TrimRight(version[:i], "vV")
+	return prefix, version[i:]
+}
+
+/*
+- Resolve the release of repo (owner/repo) for version.
+
+- An empty version (or "latest") is the latest release the policy allows, the one -list uses.
+A version that is the tag of a release is that release (a pinned tag),
+whatever the policy. A version that is a policy ("nightly") is the latest
+release that policy allows.
+
+- Any other version is a semver constraint, like "^1.2", "~0.9" or ">= 0.40, < 0.50",
+and resolves to the highest release the policy allows satisfying it (see parseTag
+and splitConstraint for the tags with a prefix). When the policy allows prereleases,
+1.5.0-rc.1 satisfies ^1.4 like 1.5.0 does. A constraint naming a prerelease
+(^1.2.0-rc.1) matches the prereleases satisfying it whatever the policy.
+*/
+func ResolveRelease(ghtoken, repo, version string, policy Policy) (Release, error) {
+	if version == "" || version == "latest" {
+		return latestRelease(ghtoken, "https://github.com/"+repo, policy)
+	}
+
+	release, found, err := ReleaseByTag(ghtoken, repo, version)
+	if err != nil || found {
+		return release, err
+	}
+	if p, err := ParsePolicy(version); err == nil {
+		return latestRelease(ghtoken, "https://github.com/"+repo, p)
+	}
+
+	c, err := parseConstraint(version)
+	if err != nil {
+		return Release{}, fmt.Errorf("%s: no release %q, and it's not a semver constraint", repo, version)
+	}
+
+	releases, err := listReleases(ghtoken, repo, maxReleasePages)
+	if err != nil {
+		return Release{}, err
+	}
+	release, found = c.highest(releases, policy)
+	if !found {
+		return Release{}, fmt.Errorf("%s: no release satisfies %q", repo, version)
+	}
+	return release, nil
+}
+
+// a semver constraint of a tools file, and the prefix of the tags it's about
+type versionConstraint struct {
+	prefix     string
+	constraint *semver.Constraints
+	// the constraint names a prerelease, like "^1.2.0-rc.1"
+	prerelease bool
+}
+
+// Parse a version that is a semver constraint (see splitConstraint)
+func parseConstraint(version string) (versionConstraint, error) {
+	prefix, c := splitConstraint(version)
+	constraint, err := semver.NewConstraint(c)
+	if err != nil {
+		return versionConstraint{}, err
+	}
+	return versionConstraint{
+		prefix:     prefix,
+		constraint: constraint,
+		prerelease: prereleaseConstraint.MatchString(c),
+	}, nil
+}
+
+// Return the highest of the releases satisfying the constraint that the policy allows
+// (see ResolveRelease), found is false if there is none
+func (c versionConstraint) highest(releases []Release, policy Policy) (release Release, found bool) {
+	var best *semver.Version
+	for _, r := range releases {
+		tagPrefix, v, ok := parseTag(r.Tag)
+		// the policy is the constraint itself when it names a prerelease
+		if !ok || (!c.prerelease && !policy.Allows(r)) || (c.prefix != "" && !strings.EqualFold(c.prefix, tagPrefix)) {
+			continue
+		}
+		// a constraint only matches prereleases when it names one, unless the policy allows them
+		check := v
+		if v.Prerelease() != "" && policy != StableOnly && policy != "" {
+			stripped, _ := v.SetPrerelease("")
+			check = &stripped
+		}
+		if !c.constraint.Check(check) {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best, release = v, r
+		}
+	}
+	return release, best != nil
+}
+
+/*
+- Return the best asset of the release for the platform.
+
+- With a pattern (a glob like "yq_*.tar.gz"), only the assets whose name
+matches it are ranked. When the pattern matches a single asset,
+it's returned even if it doesn't name the OS (the pattern picked it).
+*/
+func (r Release) Asset(p utils.Platform, pattern string) (utils.Candidate, bool) {
+	assets := r.Assets
+	if pattern != "" {
+		assets = nil
+		for _, a := range r.Assets {
+			if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(a.Name)); ok {
+				assets = append(assets, a)
+			}
+		}
+	}
+
+	ranked := utils.RankAssets(assets, p)
+	if best, found := utils.BestAsset(ranked); found {
+		return best, true
+	}
+	if pattern != "" && len(ranked) == 1 && !ranked[0].Rejected {
+		return ranked[0], true
+	}
+	return utils.Candidate{}, false
+}
+
+// Return the asset of the release named name
+func (r Release) assetNamed(name string) (utils.Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return utils.Asset{}, false
+}
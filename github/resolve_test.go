@@ -0,0 +1,136 @@
+package github
+
+import "testing"
+
+func TestNewerTag(t *testing.T) {
+	tests := []struct {
+		tag, current string
+		want         bool
+	}{
+		{"v1.2.4", "v1.2.3", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"1.2.4", "v1.2.3", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.2", "v1.2.3", false},
+		{"v1.2.3", "v1.2.3-rc.1", true},
+		{"v1.2.3-rc.1", "v1.2.3", false},
+		{"cli-v1.3.0", "cli-v1.2.0", true},
+		// another tool of the same repo
+		{"sdk-v2.0.0", "cli-v1.2.0", false},
+		// not a version
+		{"nightly", "v1.2.3", false},
+		{"v1.2.3", "nightly", false},
+	}
+	for _, tt := range tests {
+		if got := NewerTag(tt.tag, tt.current); got != tt.want {
+			t.Errorf("NewerTag(%q, %q) = %v, want %v", tt.tag, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag, prefix, version string // version is empty when the tag is not one
+	}{
+		{"v1.2.3", "", "1.2.3"},
+		{"1.2.3", "", "1.2.3"},
+		{"V1.2", "", "1.2.0"},
+		{"cli-v1.2.3", "cli-", "1.2.3"},
+		{"jq-1.7.1", "jq-", "1.7.1"},
+		{"tool_1.0.0", "tool_", "1.0.0"},
+		{"@scope/pkg@2.1.0", "@scope/pkg@", "2.1.0"},
+		{"v1.2.0-rc.1", "", "1.2.0-rc.1"},
+		// a number before the version
+		{"release-2024-v1.2.3", "release-2024-", "1.2.3"},
+		{"release-2024-1.2.3", "release-2024-", "1.2.3"},
+		{"go1.22-v0.3.0", "go1.22-", "0.3.0"},
+		{"sha256-v1.0.0", "sha256-", "1.0.0"},
+		// only a major version
+		{"v2", "", "2.0.0"},
+		{"release-2024", "release-", "2024.0.0"},
+		// not a version
+		{"nightly", "", ""},
+		{"tool", "", ""},
+		{"x86-nightly", "", ""},
+	}
+	for _, tt := range tests {
+		prefix, v, ok := parseTag(tt.tag)
+		if ok != (tt.version != "") {
+			t.Errorf("parseTag(%q) ok = %v, want %v", tt.tag, ok, !ok)
+			continue
+		}
+		if ok && (prefix != tt.prefix || v.String() != tt.version) {
+			t.Errorf("parseTag(%q) = %q, %s, want %q, %s", tt.tag, prefix, v, tt.prefix, tt.version)
+		}
+	}
+}
+
+func TestSplitConstraint(t *testing.T) {
+	tests := []struct {
+		version, prefix, constraint string
+	}{
+		{"^1.2", "", "^1.2"},
+		{"v1.4.x", "", "1.4.x"},
+		{">= 0.40, < 0.50", "", ">= 0.40, < 0.50"},
+		{"cli-^1.2", "cli-", "^1.2"},
+		{"cli-v1.x", "cli-", "1.x"},
+		{"nightly", "nightly", ""},
+	}
+	for _, tt := range tests {
+		if prefix, c := splitConstraint(tt.version); prefix != tt.prefix || c != tt.constraint {
+			t.Errorf("splitConstraint(%q) = %q, %q, want %q, %q", tt.version, prefix, c, tt.prefix, tt.constraint)
+		}
+	}
+}
+
+func TestConstraintHighest(t *testing.T) {
+	releases := []Release{
+		{Tag: "nightly"},
+		{Tag: "v2.0.0-rc.1", Prerelease: true},
+		{Tag: "v1.5.0-rc.1"},
+		{Tag: "v1.4.2"},
+		{Tag: "v1.4.10"},
+		{Tag: "v1.3.0"},
+		{Tag: "v0.9.1"},
+		{Tag: "cli-v1.6.0"},
+		{Tag: "sdk-v3.0.0"},
+		{Tag: "release-2024-v1.4.11"},
+	}
+	tests := []struct {
+		version string
+		policy  Policy
+		tag     string // empty when none satisfies it
+	}{
+		{version: "^1.2", policy: StableOnly, tag: "cli-v1.6.0"},
+		{version: "~1.4", policy: StableOnly, tag: "release-2024-v1.4.11"},
+		{version: "1.4.x", policy: StableOnly, tag: "release-2024-v1.4.11"},
+		{version: ">= 1.3, < 1.4.10", policy: StableOnly, tag: "v1.4.2"},
+		{version: "~0.9", policy: StableOnly, tag: "v0.9.1"},
+		{version: "^4", policy: StableOnly},
+		// a prefix picks the tool
+		{version: "cli-^1.2", policy: StableOnly, tag: "cli-v1.6.0"},
+		{version: "sdk-v3.x", policy: StableOnly, tag: "sdk-v3.0.0"},
+		{version: "sdk-^1", policy: StableOnly},
+		// prereleases, when the policy allows them or the constraint names one
+		{version: "^2", policy: StableOnly},
+		{version: "^2", policy: AllowPrerelease, tag: "v2.0.0-rc.1"},
+		{version: "^2.0.0-rc.1", policy: StableOnly, tag: "v2.0.0-rc.1"},
+		{version: "~1.5", policy: AllowPrerelease, tag: "v1.5.0-rc.1"},
+		{version: "~1.5", policy: StableOnly},
+	}
+	for _, tt := range tests {
+		c, err := parseConstraint(tt.version)
+		if err != nil {
+			t.Errorf("parseConstraint(%q): %v", tt.version, err)
+			continue
+		}
+		release, found := c.highest(releases, tt.policy)
+		if found != (tt.tag != "") || release.Tag != tt.tag {
+			t.Errorf("%q (%s) resolved to %q, want %q", tt.version, tt.policy, release.Tag, tt.tag)
+		}
+	}
+
+	if _, err := parseConstraint("not a version"); err == nil {
+		t.Error("parseConstraint(\"not a version\") = nil, want an error")
+	}
+}
@@ -0,0 +1,216 @@
+package github
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kavishgr/getghrel/utils"
+	"github.com/kavishgr/getghrel/verify"
+	"github.com/tidwall/gjson"
+)
+
+// Return the signature files of a release for the file name (an asset or a checksum file),
+// in the order of verify.Suffixes
+func signatureFiles(release Release, name string) []utils.Asset {
+	var sigs []utils.Asset
+	for _, suffix := range verify.Suffixes {
+		for _, a := range release.Assets {
+			if strings.EqualFold(a.Name, name+suffix) {
+				sigs = append(sigs, a)
+			}
+		}
+	}
+	return sigs
+}
+
+// Return the certificate of the keyless cosign signature of the file name
+// (name.pem or name.crt), found is false if there's none
+func certificateFile(release Release, name string) (cert utils.Asset, found bool) {
+	for _, suffix := range verify.CertSuffixes {
+		if a, ok := release.assetNamed(name + suffix); ok {
+			return a, true
+		}
+	}
+	return utils.Asset{}, false
+}
+
+/*
+- Verify the signatures of asset, saved at src, with the keys trusted for repo.
+
+- The asset is signed either itself (bat.tar.gz.minisig), or through
+the checksum file that verified it (checksums.txt.sig), which is tried first.
+Every signature is tried, the first one that verifies is returned,
+for e.g "minisign bat.tar.gz.minisig". A .sig with a certificate next to it
+(bat.tar.gz.pem) is a keyless cosign signature.
+
+- The asset is only read when it has a signature, and it's never loaded in memory.
+
+- unsigned says why the asset can't be verified: no signature in the release,
+or no trusted key for its signatures. err is set when none verifies and
+at least one doesn't (with the reason of each), the asset must not be used then.
+*/
+func verifySignature(ghtoken string, keys *verify.Keys, release Release, repo, asset, src string, digest *Digest) (signed string, unsigned, err error) {
+	var targets []string
+	if digest != nil {
+		targets = append(targets, digest.File)
+	}
+	targets = append(targets, asset)
+
+	unsigned = errors.New("no signature in the release")
+	var errs []error
+	for _, name := range targets {
+		sigs := signatureFiles(release, name)
+		if len(sigs) == 0 {
+			continue
+		}
+		var data io.ReadSeeker
+		if digest != nil && name == digest.File {
+			data = bytes.NewReader(digest.data)
+		} else {
+			f, err := os.Open(src)
+			if err != nil {
+				return "", nil, err
+			}
+			defer f.Close()
+			data = f
+		}
+
+		for _, sig := range sigs {
+			by, err := verifySignatureFile(ghtoken, keys, release, repo, name, sig, data)
+			switch {
+			case err == nil:
+				return by + " " + sig.Name, nil, nil
+			case errors.Is(err, verify.ErrNoKey):
+				unsigned = err
+			default:
+				errs = append(errs, fmt.Errorf("%s: %w", sig.Name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return "", nil, errors.Join(errs...)
+	}
+	return "", unsigned, nil
+}
+
+// Verify the signature file sig of the file name of the release, whose content is data.
+// The transparency log entries of a keyless .sig are looked up in Rekor (see rekorEntries)
+func verifySignatureFile(ghtoken string, keys *verify.Keys, release Release, repo, name string, sig utils.Asset, data io.ReadSeeker) (string, error) {
+	content, err := downloadSmall(ghtoken, sig.DownloadURL())
+	if err != nil {
+		return "", err
+	}
+	suffix := strings.ToLower(sig.Name[len(name):])
+
+	cert, ok := certificateFile(release, name)
+	if !ok || suffix != ".sig" {
+		return keys.Verify(repo, suffix, data, content)
+	}
+	certContent, err := downloadSmall(ghtoken, cert.DownloadURL())
+	if err != nil {
+		return "", err
+	}
+	// nothing is looked up for a repo without an identity to verify it with
+	var entries []byte
+	if keys.Repos[repo].Identity != "" {
+		if entries, err = rekorEntries(data); err != nil {
+			return "", err
+		}
+	}
+	return keys.VerifyKeyless(repo, data, content, certContent, entries)
+}
+
+// the Rekor transparency log the entries of keyless signatures are looked up in,
+// and how many entries of a file are fetched at most
+var rekorURL = "https://rekor.sigstore.dev"
+
+const maxRekorEntries = 10
+
+/*
+- Return the transparency log entries of data, read from its start, going by its sha256,
+as the Rekor api returns them (see verify.Keys.VerifyKeyless). nil if it has none.
+
+- Only the entries are fetched: they're verified with the pinned Rekor keys.
+*/
+func rekorEntries(data io.ReadSeeker) ([]byte, error) {
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(sum, data); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`{"hash":"sha256:%s"}`, hex.EncodeToString(sum.Sum(nil)))
+	body, err := postRekor("/api/v1/index/retrieve", query)
+	if err != nil {
+		return nil, err
+	}
+	var uuids []string
+	for _, u := range gjson.ParseBytes(body).Array() {
+		uuids = append(uuids, u.String())
+	}
+	if len(uuids) == 0 {
+		return nil, nil
+	}
+	if len(uuids) > maxRekorEntries {
+		uuids = uuids[:maxRekorEntries]
+	}
+
+	query = fmt.Sprintf(`{"entryUUIDs":["%s"]}`, strings.Join(uuids, `","`))
+	return postRekor("/api/v1/log/entries/retrieve", query)
+}
+
+// Send a json query to the Rekor api and return the body of the response
+func postRekor(path, query string) ([]byte, error) {
+	u := rekorURL + path
+	resp, err := downloadClient.Post(u, "application/json", strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxChecksumFile))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return body, nil
+}
+
+/*
+- Verify the signature of a downloaded asset with opts.Keys and record it in dl,
+digest is the one that verified the asset (nil if none did).
+
+- Return how the asset is reported: "signed: ..." or "unsigned: <reason>".
+An error is returned when a signature doesn't verify, or when the asset
+is unsigned with opts.RequireSignature.
+*/
+func checkSignature(ghtoken string, opts DownloadOptions, release Release, src string, dl *Downloaded, digest *Digest) (string, error) {
+	if dl.Repo == "" {
+		if opts.RequireSignature {
+			return "", errors.New("unsigned: not a github release asset")
+		}
+		return "unsigned: not a github release asset", nil
+	}
+
+	signed, unsigned, err := verifySignature(ghtoken, opts.Keys, release, dl.Repo, dl.Asset, src, digest)
+	switch {
+	case err != nil:
+		return "", err
+	case unsigned != nil && opts.RequireSignature:
+		return "", fmt.Errorf("unsigned: %v", unsigned)
+	case unsigned != nil:
+		return "unsigned: " + unsigned.Error(), nil
+	}
+	dl.Signed = signed
+	return "signed: " + signed, nil
+}
@@ -0,0 +1,155 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kavishgr/getghrel/utils"
+	"github.com/kavishgr/getghrel/verify"
+	"github.com/tidwall/gjson"
+)
+
+// the fixtures of the verify tests, made by ../verify/testdata/gen.go
+const verifyTestdata = "../verify/testdata"
+
+// Load the trusted keys of owner/tool (the yaml of its keys), with the sigstore roots
+// of the verify testdata
+func signatureKeys(t *testing.T, keys string) *verify.Keys {
+	t.Helper()
+	dir, err := filepath.Abs(verifyTestdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yaml := fmt.Sprintf("sigstore:\n  fulcio_roots: [%[1]s/fulcio.pem]\n  rekor_keys: [%[1]s/rekor.pub]\nrepos:\n  owner/tool:\n%[2]s", dir, keys)
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	k, err := verify.LoadKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// Serve the verify testdata, and the transparency log entry of keyless.bundle
+// as the Rekor api returns it
+func signatureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(verifyTestdata, "keyless.bundle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekor := gjson.GetBytes(data, "rekorBundle")
+	entry := fmt.Sprintf(`[{"uuid0":{"body":%s,"integratedTime":%s,"logID":%s,"logIndex":%s,"verification":{"signedEntryTimestamp":%s}}}]`,
+		rekor.Get("Payload.body").Raw, rekor.Get("Payload.integratedTime").Raw, rekor.Get("Payload.logID").Raw,
+		rekor.Get("Payload.logIndex").Raw, rekor.Get("SignedEntryTimestamp").Raw)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(verifyTestdata)))
+	mux.HandleFunc("POST /rekor/api/v1/index/retrieve", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `["uuid0"]`)
+	})
+	mux.HandleFunc("POST /rekor/api/v1/log/entries/retrieve", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, entry)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVerifySignature(t *testing.T) {
+	srv := signatureServer(t)
+	saved := rekorURL
+	rekorURL = srv.URL + "/rekor"
+	defer func() { rekorURL = saved }()
+
+	const gpgKeys = "    minisign: %s/other_minisign.pub\n    gpg: %[1]s/gpg.asc\n"
+	dir, _ := filepath.Abs(verifyTestdata)
+	tests := []struct {
+		name     string
+		keys     string
+		assets   map[string]string // name in the release -> fixture
+		signed   string
+		unsigned bool
+		wantErr  bool
+	}{
+		{
+			name:   "a signature that verifies after one that doesn't",
+			keys:   fmt.Sprintf(gpgKeys, dir),
+			assets: map[string]string{"asset.txt.minisig": "asset.txt.minisig", "asset.txt.asc": "asset.txt.asc"},
+			signed: "gpg asset.txt.asc",
+		},
+		{
+			name:   "a signature that verifies after one that can't be downloaded",
+			keys:   fmt.Sprintf(gpgKeys, dir),
+			assets: map[string]string{"asset.txt.minisig": "missing.minisig", "asset.txt.asc": "asset.txt.asc"},
+			signed: "gpg asset.txt.asc",
+		},
+		{
+			name:    "none verifies",
+			keys:    fmt.Sprintf(gpgKeys, dir),
+			assets:  map[string]string{"asset.txt.minisig": "asset.txt.minisig", "asset.txt.asc": "missing.asc"},
+			wantErr: true,
+		},
+		{
+			name:     "no trusted key",
+			keys:     fmt.Sprintf("    cosign: %s/cosign.pub\n", dir),
+			assets:   map[string]string{"asset.txt.minisig": "asset.txt.minisig"},
+			unsigned: true,
+		},
+		{
+			name:     "no signature",
+			keys:     fmt.Sprintf(gpgKeys, dir),
+			unsigned: true,
+		},
+		{
+			name:   "keyless, with its transparency log entry",
+			keys:   "    identity: https://github.com/owner/keyless/.github/workflows/*\n",
+			assets: map[string]string{"asset.txt.sig": "keyless.sig", "asset.txt.pem": "keyless.pem"},
+			signed: "sigstore https://github.com/owner/keyless/.github/workflows/release.yml@refs/tags/v1.0.0 asset.txt.sig",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := Release{Assets: []utils.Asset{{Name: "asset.txt"}}}
+			for name, fixture := range tt.assets {
+				release.Assets = append(release.Assets, utils.Asset{Name: name, URL: srv.URL + "/" + fixture})
+			}
+			keys := signatureKeys(t, tt.keys)
+			src := filepath.Join(verifyTestdata, "asset.txt")
+
+			signed, unsigned, err := verifySignature("", keys, release, "owner/tool", "asset.txt", src, nil)
+			switch {
+			case tt.wantErr:
+				if err == nil {
+					t.Fatalf("verifySignature() = %q, %v, want an error", signed, unsigned)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case (unsigned != nil) != tt.unsigned || signed != tt.signed:
+				t.Errorf("verifySignature() = %q, %v, want %q", signed, unsigned, tt.signed)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureErrors(t *testing.T) {
+	// the reason of every signature that doesn't verify
+	srv := signatureServer(t)
+	dir, _ := filepath.Abs(verifyTestdata)
+	keys := signatureKeys(t, fmt.Sprintf("    minisign: %s/other_minisign.pub\n    gpg: %[1]s/gpg.asc\n", dir))
+	release := Release{Assets: []utils.Asset{
+		{Name: "asset.txt.minisig", URL: srv.URL + "/asset.txt.minisig"},
+		{Name: "asset.txt.asc", URL: srv.URL + "/asset.txt.minisig"},
+	}}
+	_, _, err := verifySignature("", keys, release, "owner/tool", "asset.txt", filepath.Join(verifyTestdata, "asset.txt"), nil)
+	if err == nil || !strings.Contains(err.Error(), "asset.txt.minisig:") || !strings.Contains(err.Error(), "asset.txt.asc:") {
+		t.Errorf("verifySignature() = %v, want the errors of both signatures", err)
+	}
+}
@@ -0,0 +1,583 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kavishgr/getghrel/github"
+	"github.com/kavishgr/getghrel/manifest"
+	"github.com/kavishgr/getghrel/utils"
+	"github.com/kavishgr/getghrel/verify"
+)
+
+// installOptions are the settings of install
+type installOptions struct {
+	// the directory executables are installed in, usually on the PATH
+	dir string
+	// with the tree layout, where the directory tree of each asset is kept
+	datadir string
+	// rename tool-v1.2-linux-amd64 to tool
+	rename bool
+	// replace the files of the install dir getghrel didn't install (-overwrite)
+	overwrite bool
+	// the name the executable of the asset is installed as, the rename of a tools file.
+	// Only the first executable gets it, the others are named as usual
+	name string
+	// print what would be installed, without installing anything
+	dryRun bool
+	tree   bool
+	// -thin, -keep, the release policy, the version (tag or semver constraint)
+	// and the asset pattern of a tools file, only recorded in the manifest
+	thin    bool
+	keep    string
+	policy  github.Policy
+	version string
+	pattern string
+	// the keys the signature of the asset is verified with (-trustedkeys),
+	// and whether an unsigned asset is refused (-require-signature)
+	keys             *verify.Keys
+	requireSignature bool
+	// refuse the assets without a verified github attestation (-attestation)
+	attestation bool
+}
+
+// suffix of the files and directories being installed, until they're renamed into place
+const installSuffix = ".getghrel-tmp"
+
+// Return the name an executable is installed as,
+// first is true for the first executable of the asset
+func installName(binpath string, opts installOptions, first bool) string {
+	name := filepath.Base(binpath)
+	if opts.name != "" && first {
+		if strings.HasSuffix(strings.ToLower(name), ".exe") && !strings.HasSuffix(strings.ToLower(opts.name), ".exe") {
+			return opts.name + ".exe"
+		}
+		return opts.name
+	}
+	if opts.rename {
+		return utils.CommandName(name)
+	}
+	return name
+}
+
+// Reserve the name an executable is installed as.
+// false is returned (with a warning) if another executable of this run already took it
+func claim(installed map[string]string, name, asset string) bool {
+	if other, ok := installed[name]; ok {
+		fmt.Printf("Warning: %s (from %s) is not installed, %s from %s already is\n", name, asset, name, other)
+		return false
+	}
+	installed[name] = asset
+	return true
+}
+
+/*
+- Check dst can be replaced by an install: it doesn't exist,
+getghrel installed it (it's in the manifest m), or opts.overwrite is set.
+
+- A file installed by hand is not replaced, the error says so.
+With opts.dryRun, it's only a warning.
+*/
+func checkReplace(m *manifest.Manifest, dst string, opts installOptions) error {
+	if _, err := os.Lstat(dst); err != nil || opts.overwrite {
+		return nil
+	}
+	if _, ok := m.Find(dst); ok {
+		return nil
+	}
+	err := fmt.Errorf("%s was not installed by getghrel, not replaced (use -overwrite to replace it)", dst)
+	if opts.dryRun {
+		fmt.Printf("Warning: %v\n", err)
+		return nil
+	}
+	return err
+}
+
+// copy the regular file src to dst with the given permissions
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+/*
+- Move the file src to dst atomically: dst is either the old file or the new one,
+never a partially written file.
+
+- A rename is enough on the same filesystem. Otherwise (tempdir is often a tmpfs),
+src is copied next to dst first, then renamed over it.
+*/
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+installSuffix)
+	if err := copyFile(src, tmp, fi.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copy the directory tree src to dst, symlinks included
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return copyFile(p, target, fi.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+/*
+- Move the directory src to dst, replacing dst if it exists
+(the same version installed again).
+
+- Like moveFile, src is copied next to dst when a rename is not possible,
+and the old dst is only removed once the new one is in place.
+*/
+func moveDir(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	tmp := dst + installSuffix
+	os.RemoveAll(tmp)
+	if err := os.Rename(src, tmp); err != nil {
+		if err := copyDir(src, tmp); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+		os.RemoveAll(src)
+	}
+
+	old := dst + ".getghrel-old"
+	if _, err := os.Lstat(dst); err == nil {
+		os.RemoveAll(old)
+		if err := os.Rename(dst, old); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// Create (or replace) the symlink link pointing to target atomically
+func replaceSymlink(target, link string) error {
+	tmp := filepath.Join(filepath.Dir(link), "."+filepath.Base(link)+installSuffix)
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Install the files kept by cleanup from an asset with the flat layout,
+// and record them in tool. m is the manifest, of the files already installed
+func installFiles(m *manifest.Manifest, dl github.Downloaded, opts installOptions, installed map[string]string, tool *manifest.Tool) error {
+	seen := make(map[string]bool)
+	first := true
+	for _, f := range dl.Files {
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+
+		// removed by cleanup
+		fi, err := os.Lstat(f)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+
+		name := installName(f, opts, first)
+		first = false
+		if !claim(installed, name, dl.Asset) {
+			continue
+		}
+		dst := filepath.Join(opts.dir, name)
+		if err := checkReplace(m, dst, opts); err != nil {
+			return err
+		}
+
+		if opts.dryRun {
+			fmt.Printf("Would install: %s -> %s\n", f, dst)
+			continue
+		}
+		if err := moveFile(f, dst); err != nil {
+			return err
+		}
+		if err := recordFile(tool, dst); err != nil {
+			return err
+		}
+		fmt.Printf("Installed: %s\n", dst)
+	}
+	return nil
+}
+
+/*
+- Install an asset extracted with the tree layout.
+
+- The tree is moved to <datadir>/<owner>_<repo>/<version>, and the executables cleanup
+exposed in tempdir/bin are linked in the install dir.
+
+- Every file of the tree and every link is recorded in tool.
+*/
+func installTree(m *manifest.Manifest, tempdir string, dl github.Downloaded, opts installOptions, installed map[string]string, tool *manifest.Tool) error {
+	rel, err := filepath.Rel(tempdir, dl.Dir)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(opts.datadir, rel)
+
+	// the executables of the asset, relative to its tree
+	var executables []string
+	bindir := filepath.Join(tempdir, "bin")
+	entries, _ := os.ReadDir(bindir)
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(bindir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		exe, err := filepath.Rel(dl.Dir, filepath.Join(bindir, target))
+		if err != nil || exe == ".." || strings.HasPrefix(exe, ".."+string(filepath.Separator)) {
+			continue
+		}
+		executables = append(executables, exe)
+	}
+
+	if opts.dryRun {
+		fmt.Printf("Would install: %s -> %s\n", dl.Dir, dst)
+	} else {
+		if err := moveDir(dl.Dir, dst); err != nil {
+			return err
+		}
+		tool.Dir = dst
+		err := filepath.WalkDir(dst, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			return recordFile(tool, p)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, exe := range executables {
+		name := installName(exe, opts, i == 0)
+		if !claim(installed, name, dl.Asset) {
+			continue
+		}
+		link := filepath.Join(opts.dir, name)
+		if err := checkReplace(m, link, opts); err != nil {
+			return err
+		}
+
+		if opts.dryRun {
+			fmt.Printf("Would link: %s -> %s\n", link, filepath.Join(dst, exe))
+			continue
+		}
+		if err := replaceSymlink(filepath.Join(dst, exe), link); err != nil {
+			return err
+		}
+		tool.Links = append(tool.Links, link)
+		fmt.Printf("Installed: %s -> %s\n", link, filepath.Join(dst, exe))
+	}
+	return nil
+}
+
+// Remove what the installed assets left in tempdir, then tempdir itself if it's empty
+func removeLeftovers(tempdir string, downloads []github.Downloaded) {
+	for _, dl := range downloads {
+		for _, f := range dl.Files {
+			os.Remove(f)
+		}
+		if dl.Dir != "" {
+			os.RemoveAll(dl.Dir)
+			// <owner>_<repo> once its <version> is gone
+			os.Remove(filepath.Dir(dl.Dir))
+		}
+	}
+	os.RemoveAll(filepath.Join(tempdir, "bin"))
+
+	if err := os.Remove(tempdir); err != nil {
+		fmt.Printf("%s: not deleted...\n", tempdir)
+	}
+}
+
+// Record an installed file and its checksum in tool
+func recordFile(tool *manifest.Tool, path string) error {
+	sum, err := manifest.FileSHA256(path)
+	if err != nil {
+		return err
+	}
+	tool.Files = append(tool.Files, manifest.File{Path: path, SHA256: sum})
+	return nil
+}
+
+/*
+- Install the executables kept by cleanup in opts.dir,
+replacing the ones getghrel installed there with the same name.
+A file getghrel didn't install is only replaced with opts.overwrite.
+
+- With opts.rename, tool-v1.2-linux-amd64 is installed as tool.
+When two executables end up with the same name, the first one wins.
+
+- What's installed is recorded in the manifest of opts.datadir:
+the repo, version and asset each file came from, and its checksum.
+opts.dir and opts.datadir are made absolute first, so are the recorded paths.
+
+- Once everything is installed, tempdir is cleaned up.
+With opts.dryRun, nothing is moved: what would be installed is printed.
+*/
+func install(tempdir string, downloads []github.Downloaded, opts installOptions) error {
+	// the paths recorded in the manifest must not depend on the working directory
+	var err error
+	if opts.dir, err = filepath.Abs(opts.dir); err != nil {
+		return err
+	}
+	if opts.datadir, err = filepath.Abs(opts.datadir); err != nil {
+		return err
+	}
+
+	manifestPath := manifest.Path(opts.datadir)
+	m, err := manifest.Load(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	if !opts.dryRun {
+		if err := os.MkdirAll(opts.dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	installed := make(map[string]string)
+	failed := false
+	for _, dl := range downloads {
+		tool := manifest.Tool{
+			Repo:         dl.Repo,
+			Tag:          dl.Tag,
+			Asset:        dl.Asset,
+			URL:          dl.URL,
+			Platform:     dl.Platform.String(),
+			Libc:         dl.Platform.Libc,
+			Verified:     dl.Verified,
+			Signed:       dl.Signed,
+			Attested:     dl.Attested,
+			InstallDir:   opts.dir,
+			Rename:       opts.rename,
+			Name:         opts.name,
+			Policy:       string(opts.policy),
+			Version:      opts.version,
+			AssetPattern: opts.pattern,
+			Thin:         opts.thin,
+			Keep:         opts.keep,
+		}
+
+		var err error
+		if opts.tree {
+			err = installTree(m, tempdir, dl, opts, installed, &tool)
+		} else {
+			err = installFiles(m, dl, opts, installed, &tool)
+		}
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", dl.Asset, err)
+			failed = true
+		}
+
+		// what was installed before an error is recorded as well
+		if len(tool.Files) > 0 {
+			tool.Time = time.Now().UTC()
+			m.Record(tool)
+		}
+	}
+
+	if !opts.dryRun {
+		if err := m.Save(manifestPath); err != nil {
+			return err
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("not every asset was installed, what's left is inside: %s", tempdir)
+	}
+	if !opts.dryRun {
+		removeLeftovers(tempdir, downloads)
+	}
+	return nil
+}
+
+// an asset of a release resolved for a platform, the one installRelease installs
+type releaseAsset struct {
+	repo     string
+	tag      string
+	asset    utils.Candidate
+	platform utils.Platform
+	// with -locked, the size and sha256 the asset must have
+	checksum *github.Checksum
+}
+
+/*
+- Download an asset of a release, keep its executables and install them,
+then remove what's left of the version of the tool installed before it
+(same repo and platform), if any.
+
+- Used by getghrel upgrade and getghrel sync. opts.keep is the -keep
+of cleanup, "binary,appimage" when empty.
+*/
+func installRelease(rel releaseAsset, opts installOptions, ghtoken string) error {
+	repo, tag, asset, p := rel.repo, rel.tag, rel.asset, rel.platform
+
+	keepList := opts.keep
+	if keepList == "" {
+		keepList = "binary,appimage"
+	}
+	keep, err := keepKinds(keepList)
+	if err != nil {
+		return err
+	}
+
+	manifestPath := manifest.Path(opts.datadir)
+	m, err := manifest.Load(manifestPath)
+	if err != nil {
+		return err
+	}
+	var old *manifest.Tool
+	for _, t := range m.Tools {
+		if t.Repo == repo && t.Platform == p.String() {
+			old = &t
+			break
+		}
+	}
+
+	tempdir, err := os.MkdirTemp("", "getghrel-install-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	layout := "flat"
+	if opts.tree {
+		layout = "tree"
+	}
+
+	urls := make(chan string, 1)
+	urls <- asset.URL
+	close(urls)
+
+	var checksums map[string]github.Checksum
+	if rel.checksum != nil {
+		checksums = map[string]github.Checksum{asset.URL: *rel.checksum}
+	}
+
+	var job sync.WaitGroup
+	downloads := &github.Downloads{}
+	job.Add(1)
+	github.DownloadRelease(urls, &job, github.DownloadOptions{
+		GHToken:          ghtoken,
+		TempDir:          tempdir,
+		Platforms:        []utils.Platform{p},
+		Downloads:        downloads,
+		Layout:           layout,
+		Extract:          utils.DefaultExtractOptions,
+		Checksums:        checksums,
+		Keys:             opts.keys,
+		RequireSignature: opts.requireSignature,
+		Attestation:      opts.attestation,
+	})
+	dls := downloads.List()
+	if len(dls) == 0 {
+		return fmt.Errorf("%s was not downloaded", asset.Name)
+	}
+	// recorded as the release it was resolved from
+	for i := range dls {
+		dls[i].Repo, dls[i].Tag = repo, tag
+	}
+
+	cleanup(tempdir, p, cleanupOptions{
+		origins: downloads.Origins(),
+		thin:    opts.thin,
+		keep:    keep,
+		tree:    opts.tree,
+	})
+
+	if err := install(tempdir, dls, opts); err != nil {
+		return err
+	}
+	if old == nil || opts.dryRun {
+		return nil
+	}
+
+	m, err = manifest.Load(manifestPath)
+	if err != nil {
+		return err
+	}
+	for _, current := range m.Tools {
+		if current.Repo == repo && current.Platform == p.String() {
+			removeOldVersion(*old, current)
+			return nil
+		}
+	}
+	return errors.New("the new version is missing from the manifest")
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kavishgr/getghrel/manifest"
+	"github.com/kavishgr/getghrel/options"
+)
+
+// Return the name of a tool: its repo, or its asset when it doesn't come from a github release
+func toolName(t manifest.Tool) string {
+	if t.Repo != "" {
+		return t.Repo
+	}
+	return t.Asset
+}
+
+// Return the paths of a tool a user runs: the links of the tree layout,
+// or the files installed with the flat layout
+func toolPaths(t manifest.Tool) []string {
+	if t.Dir == "" {
+		var paths []string
+		for _, f := range t.Files {
+			paths = append(paths, f.Path)
+		}
+		return paths
+	}
+	if len(t.Links) == 0 {
+		return []string{t.Dir}
+	}
+	return t.Links
+}
+
+// getghrel installed: list the installed tools as a table, or as JSON with -json
+func installedCommand(args []string) int {
+	opts := options.ParseCommand("installed", args)
+
+	m, err := manifest.Load(manifest.Path(opts.DataDir))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	tools := m.Tools
+	sort.SliceStable(tools, func(i, j int) bool {
+		return toolName(tools[i]) < toolName(tools[j])
+	})
+
+	if opts.JSON {
+		if tools == nil {
+			tools = []manifest.Tool{}
+		}
+		data, err := json.MarshalIndent(tools, "", "  ")
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Println(string(data))
+		return 0
+	}
+
+	if len(tools) == 0 {
+		fmt.Println("No tools installed by getghrel")
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tVERSION\tPLATFORM\tPATH\tINSTALLED")
+	for _, t := range tools {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", toolName(t), t.Tag, t.Platform,
+			strings.Join(toolPaths(t), ", "), t.Time.Local().Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+	return 0
+}
+
+// Remove the empty directories of dir, and dir itself if it ends up empty.
+// A directory holding files getghrel didn't install is kept
+func pruneDir(dir string) {
+	var dirs []string
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	// deepest first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
+
+// Check the symlink l points to one of the files of the tool, in its directory
+func linksToTool(t manifest.Tool, l string) bool {
+	target, err := os.Readlink(l)
+	if err != nil || t.Dir == "" {
+		return false
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(l), target)
+	}
+	target = filepath.Clean(target)
+	if rel, err := filepath.Rel(t.Dir, target); err != nil || !filepath.IsLocal(rel) {
+		return false
+	}
+	_, ok := t.File(target)
+	return ok
+}
+
+/*
+- Remove the files and links of an installed tool.
+
+- Nothing is removed if one of its files was modified since it was installed:
+the files that don't match their checksum are returned in the error.
+A link that was pointed somewhere else since is kept, with a warning.
+*/
+func uninstallTool(t manifest.Tool, dryRun bool) error {
+	var modified []string
+	for _, f := range t.Files {
+		if status := fileStatus(f); status != "unchanged" && status != "missing" {
+			modified = append(modified, f.Path)
+		}
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("modified since it was installed, not removed: %s", strings.Join(modified, ", "))
+	}
+
+	for _, l := range t.Links {
+		// only if it's still the link getghrel created
+		if _, err := os.Lstat(l); err != nil {
+			continue
+		}
+		if !linksToTool(t, l) {
+			fmt.Printf("Warning: %s no longer links to %s, not removed\n", l, t.Dir)
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would remove: %s\n", l)
+			continue
+		}
+		if err := os.Remove(l); err != nil {
+			return err
+		}
+		fmt.Printf("Removed: %s\n", l)
+	}
+
+	for _, f := range t.Files {
+		if _, err := os.Lstat(f.Path); err != nil {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would remove: %s\n", f.Path)
+			continue
+		}
+		if err := os.Remove(f.Path); err != nil {
+			return err
+		}
+		if t.Dir == "" {
+			fmt.Printf("Removed: %s\n", f.Path)
+		}
+	}
+
+	if t.Dir != "" && !dryRun {
+		pruneDir(t.Dir)
+		// <owner>_<repo> once its <version> is gone
+		os.Remove(filepath.Dir(t.Dir))
+		fmt.Printf("Removed: %s\n", t.Dir)
+	}
+	return nil
+}
+
+/*
+- getghrel uninstall <owner/repo|binary>...: remove the files and links
+of installed tools, and their record in the manifest.
+
+- A tool is found by its repo (every platform it was installed for)
+or by the name or path of one of its binaries.
+*/
+func uninstallCommand(args []string) int {
+	opts := options.ParseCommand("uninstall", args)
+	if len(opts.Args) == 0 {
+		fmt.Println("Usage: getghrel uninstall <owner/repo|binary>...")
+		return 1
+	}
+
+	manifestPath := manifest.Path(opts.DataDir)
+	m, err := manifest.Load(manifestPath)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	status := 0
+	for _, arg := range opts.Args {
+		var tools []manifest.Tool
+		for _, t := range m.Tools {
+			if t.Repo == arg {
+				tools = append(tools, t)
+			}
+		}
+		if len(tools) == 0 {
+			if t, _, ok := findBinary(m, arg); ok {
+				tools = append(tools, t)
+			}
+		}
+		if len(tools) == 0 {
+			fmt.Printf("%s: not installed by getghrel\n", arg)
+			status = 1
+			continue
+		}
+
+		for _, t := range tools {
+			if err := uninstallTool(t, opts.DryRun); err != nil {
+				fmt.Printf("Error: %s: %v\n", toolName(t), err)
+				status = 1
+				continue
+			}
+			if !opts.DryRun {
+				m.Remove(t)
+			}
+		}
+	}
+
+	if !opts.DryRun {
+		if err := m.Save(manifestPath); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	return status
+}
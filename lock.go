@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/kavishgr/getghrel/config"
+	"github.com/kavishgr/getghrel/github"
+	"github.com/kavishgr/getghrel/options"
+	"github.com/kavishgr/getghrel/utils"
+)
+
+/*
+- Resolve the release of a tool of the tools file and pin its asset
+for each platform it targets.
+
+- The linux assets are resolved for the libc getghrel sync would use
+(see withLibc), and the libc is recorded with them. The size and sha256 come
+from github when the release has them, otherwise the asset is downloaded to hash it.
+*/
+func lockTool(ghtoken string, c *config.Config, t config.Tool, host utils.Platform) (config.LockedTool, error) {
+	platforms, err := c.LockPlatforms(t, host)
+	if err != nil {
+		return config.LockedTool{}, err
+	}
+
+	release, err := github.ResolveRelease(ghtoken, t.Repo, t.Version, github.Policy(t.Policy))
+	if err != nil {
+		return config.LockedTool{}, err
+	}
+	if release.Tag == "" {
+		return config.LockedTool{}, errors.New("no release found")
+	}
+
+	locked := config.LockedTool{
+		Version:   t.Version,
+		Pattern:   t.Asset,
+		Policy:    t.Policy,
+		Tag:       release.Tag,
+		Platforms: make(map[string]config.LockedAsset),
+	}
+	for _, p := range platforms {
+		if p, err = withLibc(p); err != nil {
+			return config.LockedTool{}, err
+		}
+		asset, found := release.Asset(p, t.Asset)
+		if !found {
+			return config.LockedTool{}, fmt.Errorf("%s: no asset for %s", release.Tag, p)
+		}
+
+		sum := github.Checksum{Size: asset.Size, SHA256: asset.SHA256}
+		if sum.SHA256 == "" || sum.Size == 0 {
+			if sum, err = github.HashAsset(ghtoken, asset.DownloadURL()); err != nil {
+				return config.LockedTool{}, err
+			}
+		}
+		entry := config.LockedAsset{
+			Asset:  asset.Name,
+			URL:    asset.URL,
+			Size:   sum.Size,
+			SHA256: sum.SHA256,
+		}
+		if p.OS == "linux" {
+			entry.Libc = p.Libc
+		}
+		locked.Platforms[p.String()] = entry
+	}
+	return locked, nil
+}
+
+/*
+- getghrel lock -f tools.yaml [owner/repo...]: write the lockfile of the tools file
+(tools.yaml -> tools.lock), pinning every tool to an exact tag, and its asset url,
+size and sha256 for each of its targets. getghrel sync -locked installs exactly those.
+
+- The tools already locked are kept as they are, unless their version,
+asset pattern or policy changed in the tools file, or they're given on the command line
+(to move them to their latest matching release).
+Nothing is written if a tool can't be locked.
+*/
+func lockCommand(args []string) int {
+	opts := options.ParseCommand("lock", args)
+	if opts.GHToken == "" {
+		fmt.Println("GITHUB_TOKEN environment variable is not found.")
+		fmt.Println("Nor is -ghtoken provided on the command line.")
+		return 1
+	}
+
+	c, err := config.Load(opts.File)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	lockPath := config.LockPath(opts.File)
+	old, err := config.LoadLock(lockPath)
+	if os.IsNotExist(err) {
+		old, err = &config.Lock{}, nil
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	host := utils.HostPlatform()
+
+	lock := &config.Lock{Tools: make(map[string]config.LockedTool)}
+	errs := make([]error, len(c.Tools))
+	var mu sync.Mutex
+	var jobs sync.WaitGroup
+	sem := make(chan struct{}, upgradeConcurrency)
+	for i, t := range c.Tools {
+		if locked, ok := old.Tools[t.Repo]; ok && !slices.Contains(opts.Args, t.Repo) && lockedFor(c, t, locked, host) {
+			lock.Tools[t.Repo] = locked
+			continue
+		}
+
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			locked, err := lockTool(opts.GHToken, c, t, host)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			mu.Lock()
+			lock.Tools[t.Repo] = locked
+			mu.Unlock()
+			fmt.Printf("Locked: %s %s\n", t.Repo, locked.Tag)
+		}()
+	}
+	jobs.Wait()
+
+	status := 0
+	for i, err := range errs {
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", c.Tools[i].Repo, err)
+			status = 1
+		}
+	}
+	if status != 0 {
+		fmt.Printf("%s was not written\n", lockPath)
+		return status
+	}
+
+	if err := lock.Save(lockPath); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("Written: %s\n", lockPath)
+	return 0
+}
+
+// Return true if a tool is still locked the way the tools file wants it:
+// same version, asset pattern and policy, and an asset for each of its targets
+// resolved for the libc getghrel sync uses here
+func lockedFor(c *config.Config, t config.Tool, locked config.LockedTool, host utils.Platform) bool {
+	if !locked.Matches(t) {
+		return false
+	}
+	platforms, err := c.LockPlatforms(t, host)
+	if err != nil {
+		return false
+	}
+	for _, p := range platforms {
+		asset, ok := locked.Platforms[p.String()]
+		if !ok {
+			return false
+		}
+		if p, err = withLibc(p); err != nil || !asset.LockedFor(p) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,25 +1,47 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/kavishgr/getghrel/github"
 	"github.com/kavishgr/getghrel/options"
 	"github.com/kavishgr/getghrel/utils"
+	"github.com/kavishgr/getghrel/verify"
 )
 
 func main() {
 
+	// getghrel <command> [flags] [args]
+	if len(os.Args) > 1 && options.IsCommand(os.Args[1]) {
+		switch os.Args[1] {
+		case "which":
+			os.Exit(whichCommand(os.Args[2:]))
+		case "upgrade":
+			os.Exit(upgradeCommand(os.Args[2:]))
+		case "installed":
+			os.Exit(installedCommand(os.Args[2:]))
+		case "uninstall":
+			os.Exit(uninstallCommand(os.Args[2:]))
+		case "sync":
+			os.Exit(syncCommand(os.Args[2:]))
+		case "lock":
+			os.Exit(lockCommand(os.Args[2:]))
+		}
+	}
+
 	var (
 		opts           = options.ParseFlags()
 		skipextraction = opts.SkipExtraction
 		token          = opts.GHToken
 		tempdir        = opts.TempDir
-		ost, arch      = utils.OsInfo()
-		regex          = utils.SetRegex(ost, arch)
 		stdInUrls      = make(chan string)
+		downloads      = &github.Downloads{}
 		jobs           sync.WaitGroup
 		version        = "0.1.2"
 	)
@@ -44,12 +66,57 @@ func main() {
 		os.Exit(1)
 	}
 
+	platforms, err := targetPlatforms(opts.OS, opts.Arch, opts.Libc, opts.Targets)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println("File an issue or make a pull request for your OS and Arch")
+		os.Exit(1)
+	}
+
+	keep, err := keepKinds(opts.Keep)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if opts.Layout != "flat" && opts.Layout != "tree" {
+		fmt.Printf("-layout %q is not supported, use: flat, tree\n", opts.Layout)
+		os.Exit(1)
+	}
+
+	policy, err := github.ParsePolicy(opts.Policy)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	keys, err := trustedKeys(opts.TrustedKeys, opts.RequireSig, opts.Attestation)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if opts.MaxDepth < 1 {
+		fmt.Println("-maxdepth must be at least 1")
+		os.Exit(1)
+	}
+
+	if opts.Install && (skipextraction || len(platforms) > 1) {
+		fmt.Println("-install can't be used with -skipextraction or multiple -target")
+		os.Exit(1)
+	}
+
+	if (opts.Rename || opts.Overwrite || opts.DryRun) && !opts.Install {
+		fmt.Println("-rename, -overwrite and -dryrun are only used with -install")
+		os.Exit(1)
+	}
+
 	go utils.ScanStdIn(stdInUrls)
 
 	if opts.List {
 		for c := 0; c < opts.Concurrency; c++ {
 			jobs.Add(1)
-			go github.FetchGithubReleaseUrl(stdInUrls, &jobs, regex, token)
+			go github.FetchGithubReleaseUrl(stdInUrls, &jobs, platforms, token, opts.Rank, policy)
 		}
 	}
 
@@ -63,9 +130,26 @@ func main() {
 			}
 		}
 
+		downloadOpts := github.DownloadOptions{
+			GHToken:        token,
+			TempDir:        tempdir,
+			SkipExtraction: skipextraction,
+			Platforms:      platforms,
+			Downloads:      downloads,
+			Layout:         opts.Layout,
+			Extract: utils.ExtractOptions{
+				MaxSize:    opts.MaxSize << 20, // MB
+				MaxEntries: opts.MaxEntries,
+				MaxDepth:   opts.MaxDepth,
+			},
+			Keys:             keys,
+			RequireSignature: opts.RequireSig,
+			Attestation:      opts.Attestation,
+		}
+
 		for c := 0; c < opts.Concurrency; c++ {
 			jobs.Add(1)
-			go github.DownloadRelease(stdInUrls, &jobs, token, tempdir, skipextraction)
+			go github.DownloadRelease(stdInUrls, &jobs, downloadOpts)
 		}
 	}
 
@@ -80,8 +164,128 @@ func main() {
 		fmt.Println("Archives are inside: ", tempdir)
 
 	default:
-		cleanup(tempdir)
+		cleanupOpts := cleanupOptions{
+			origins: downloads.Origins(),
+			thin:    opts.Thin,
+			keep:    keep,
+			tree:    opts.Layout == "tree",
+		}
+		for _, p := range platforms {
+			cleanup(utils.PlatformDir(tempdir, p, platforms), p, cleanupOpts)
+		}
 		fmt.Println("")
+		if opts.Install {
+			installOpts := installOptions{
+				dir:       opts.InstallDir,
+				datadir:   opts.DataDir,
+				rename:    opts.Rename,
+				overwrite: opts.Overwrite,
+				dryRun:    opts.DryRun,
+				tree:      opts.Layout == "tree",
+				thin:      opts.Thin,
+				keep:      opts.Keep,
+			}
+			if err := install(tempdir, downloads.List(), installOpts); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if !opts.DryRun {
+				fmt.Println("")
+				fmt.Println("All Binaries are installed inside: ", opts.InstallDir)
+				if !onPath(opts.InstallDir) {
+					fmt.Printf("Warning: %s is not in your PATH\n", opts.InstallDir)
+				}
+			}
+			break
+		}
+		if opts.Layout == "tree" {
+			// each platform has its own bin directory
+			for _, p := range platforms {
+				fmt.Println("All Binaries are exposed inside: ", filepath.Join(utils.PlatformDir(tempdir, p, platforms), "bin"))
+			}
+			break
+		}
 		fmt.Println("All Binaries are inside: ", tempdir)
 	}
 }
+
+/*
+- Return the platforms to list/download releases for.
+
+- Every -target is parsed as an os/arch pair.
+Without -target, it's the platform getghrel is running on,
+with -os and -arch overriding the host OS and Architecture.
+
+- The libc of each platform comes from -libc,
+or is detected when the platform is the host itself.
+*/
+func targetPlatforms(ost, arch, libc string, targets []string) ([]utils.Platform, error) {
+	if len(targets) == 0 {
+		host := utils.HostPlatform()
+		if ost != "" {
+			host.OS = ost
+		}
+		if arch != "" {
+			host.Arch = arch
+			host.Variant = ""
+		}
+		targets = []string{host.String()}
+	} else if ost != "" || arch != "" {
+		return nil, fmt.Errorf("-os and -arch can't be used with -target")
+	}
+
+	var platforms []utils.Platform
+	for _, t := range targets {
+		p, err := utils.ParsePlatform(t)
+		if err != nil {
+			return nil, err
+		}
+		// the libc of the linux assets: gnu, musl or any
+		if p.Libc, err = utils.TargetLibc(p, libc); err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
+
+// Parse the comma separated kinds of files given to -keep
+func keepKinds(list string) (map[string]bool, error) {
+	keep := make(map[string]bool)
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		if !slices.Contains(fileKinds, kind) {
+			return nil, fmt.Errorf("-keep %q is not supported, use: %s", kind, strings.Join(fileKinds, ", "))
+		}
+		keep[kind] = true
+	}
+	return keep, nil
+}
+
+// Load the trusted-keys file of -trustedkeys, nil without one.
+// -require-signature and -attestation can't be used without it, nothing could be verified
+func trustedKeys(path string, requireSig, attestation bool) (*verify.Keys, error) {
+	if path == "" {
+		switch {
+		case requireSig:
+			return nil, errors.New("-require-signature needs -trustedkeys")
+		case attestation:
+			return nil, errors.New("-attestation needs -trustedkeys, for the pinned sigstore roots")
+		}
+		return nil, nil
+	}
+	return verify.LoadKeys(path)
+}
+
+// Return true if dir is one of the directories of the PATH
+func onPath(dir string) bool {
+	for _, p := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(p) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
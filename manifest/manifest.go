@@ -0,0 +1,217 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File is a file getghrel installed and its checksum at install time
+type File struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Tool is an asset getghrel installed, where it came from and what it put on disk
+type Tool struct {
+	Repo     string `json:"repo"` // owner/repo, empty for an asset that's not from a github release
+	Tag      string `json:"tag"`
+	Asset    string `json:"asset"`
+	URL      string `json:"url"`
+	Platform string `json:"platform"` // os/arch[/variant]
+	Libc     string `json:"libc,omitempty"`
+	// how the asset was verified, for e.g "sha256 from checksums.txt", empty if it was not
+	Verified string `json:"verified,omitempty"`
+	// the signature that verified the asset, for e.g "minisign bat.tar.gz.minisig", empty if none did
+	Signed string `json:"signed,omitempty"`
+	// the workflow that built the asset, going by its verified attestation
+	Attested string `json:"attested,omitempty"`
+	// with the tree layout, the directory the tree of the asset is in
+	Dir   string    `json:"dir,omitempty"`
+	Files []File    `json:"files"`
+	Links []string  `json:"links,omitempty"` // symlinks to the executables of Dir
+	Time  time.Time `json:"installed_at"`
+
+	// how it was installed (-installdir, -rename, -thin and -keep, the rename, policy,
+	// version and asset pattern of a tools file), so an upgrade installs the new version the same way
+	InstallDir   string `json:"installdir"`
+	Rename       bool   `json:"rename,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Policy       string `json:"policy,omitempty"`
+	Version      string `json:"version,omitempty"` // a pinned tag or a semver constraint
+	AssetPattern string `json:"asset_pattern,omitempty"`
+	Thin         bool   `json:"thin,omitempty"`
+	Keep         string `json:"keep,omitempty"`
+}
+
+// Manifest is the record of every tool getghrel installed,
+// saved as JSON in the data dir
+type Manifest struct {
+	Tools []Tool `json:"tools"`
+}
+
+// Return the path of the manifest inside datadir
+func Path(datadir string) string {
+	return filepath.Join(datadir, "manifest.json")
+}
+
+// Load the manifest at path, a missing manifest is an empty one
+func Load(path string) (*Manifest, error) {
+	m := &Manifest{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Save the manifest at path, a partially written manifest is never left behind
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".getghrel-tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Return true if both tools are the same asset for the same platform, in any version
+func (t Tool) same(o Tool) bool {
+	if t.Platform != o.Platform {
+		return false
+	}
+	if t.Repo != "" || o.Repo != "" {
+		return t.Repo == o.Repo
+	}
+	return t.URL == o.URL
+}
+
+// Return true if path is a file or a link of the tool
+func (t Tool) Owns(path string) bool {
+	for _, f := range t.Files {
+		if f.Path == path {
+			return true
+		}
+	}
+	for _, l := range t.Links {
+		if l == path {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+- Record a tool that was just installed.
+
+- It replaces the record of the same tool (another version of it),
+and the files it replaced on disk are removed from the records of other tools.
+A tool left without any file is removed.
+*/
+func (m *Manifest) Record(t Tool) {
+	var tools []Tool
+	for _, o := range m.Tools {
+		if o.same(t) {
+			continue
+		}
+		var files []File
+		for _, f := range o.Files {
+			if !t.Owns(f.Path) {
+				files = append(files, f)
+			}
+		}
+		var links []string
+		for _, l := range o.Links {
+			if !t.Owns(l) {
+				links = append(links, l)
+			}
+		}
+		o.Files, o.Links = files, links
+		if len(o.Files) > 0 {
+			tools = append(tools, o)
+		}
+	}
+	m.Tools = append(tools, t)
+}
+
+// Remove the record of a tool
+func (m *Manifest) Remove(t Tool) {
+	var tools []Tool
+	for _, o := range m.Tools {
+		if !o.same(t) {
+			tools = append(tools, o)
+		}
+	}
+	m.Tools = tools
+}
+
+// Return the tool a file or a link on disk belongs to
+func (m *Manifest) Find(path string) (Tool, bool) {
+	for _, t := range m.Tools {
+		if t.Owns(path) {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+// Return the tool a file or a link named name belongs to, and the path of that file or link
+func (m *Manifest) FindName(name string) (Tool, string, bool) {
+	for _, t := range m.Tools {
+		for _, l := range t.Links {
+			if filepath.Base(l) == name {
+				return t, l, true
+			}
+		}
+		for _, f := range t.Files {
+			if filepath.Base(f.Path) == name {
+				return t, f.Path, true
+			}
+		}
+	}
+	return Tool{}, "", false
+}
+
+// Return the record of a file of the tool
+func (t Tool) File(path string) (File, bool) {
+	for _, f := range t.Files {
+		if f.Path == path {
+			return f, true
+		}
+	}
+	return File{}, false
+}
+
+// Return the SHA-256 of the file at path, hex encoded
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
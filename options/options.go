@@ -3,19 +3,67 @@ package options
 import (
 	"flag"
 	// "fmt"
+	"github.com/kavishgr/getghrel/utils"
 	"github.com/mitchellh/colorstring"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 )
 
 type options struct {
 	List           bool
+	Rank           bool
+	Policy         string
 	Download       bool
 	SkipExtraction bool
 	Concurrency    int
 	GHToken        string
 	TempDir        string
 	Version        bool
+	OS             string
+	Arch           string
+	Targets        targets
+	Libc           string
+	Thin           bool
+	Keep           string
+	Layout         string
+	MaxSize        int64
+	MaxEntries     int
+	MaxDepth       int
+	Install        bool
+	InstallDir     string
+	DataDir        string
+	Rename         bool
+	Overwrite      bool
+	DryRun         bool
+	TrustedKeys    string
+	RequireSig     bool
+	Attestation    bool
+}
+
+// targets is a repeatable flag: -target linux/amd64 -target darwin/arm64
+type targets []string
+
+func (t *targets) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *targets) Set(value string) error {
+	*t = append(*t, value)
+	return nil
+}
+
+// where -install puts the binaries
+func defaultInstallDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "bin")
+}
+
+// where the manifest and the trees of -layout tree are kept
+func defaultDataDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share", "getghrel")
 }
 
 func ParseFlags() options {
@@ -28,10 +76,16 @@ func ParseFlags() options {
 			"[light_cyan]Usage:[reset]",
 			"",
 			"  echo 'https://github.com/sharkdp/bat' | getghrel -list | sort",
-			"  cat urls.txt | getghrel -list | sort | tee releases.txt",
+			"  cat urls.txt | getghrel -list | tee releases.txt",
 			"  cat releases.txt | getghrel -download",
 			"  cat releases.txt | getghrel -download -tempdir '/tmp/bin'",
-			"  cat urls.txt | getghrel -list | grep -vi '^n/a'",
+			"  echo 'sharkdp/bat' | getghrel -list -rank",
+			"  getghrel which bat",
+			"  getghrel upgrade",
+			"  getghrel installed",
+			"  getghrel uninstall sharkdp/bat",
+			"  getghrel sync -f tools.yaml",
+			"  getghrel lock -f tools.yaml",
 			" ",
 			"[light_cyan]The url format for -list[reset]: \n",
 			"  A github url -> 'https://github.com/owner/repo'",
@@ -43,10 +97,24 @@ func ParseFlags() options {
 			"Options:",
 			"  [light_cyan]-list[reset]",
 			"",
-			"\tWill list all the release/releases found for your OS and Architecture.\n",
-			"\tExample: cat urls.txt | getghrel -list | sort",
-			"\tExample: echo 'https://github.com/sharkdp/bat' | getghrel -list | sort",
-			"\tExample: echo 'sharkdp/bat' | getghrel -list | sort",
+			"\tWill list the best release asset found for your OS and Architecture.\n",
+			"\tExample: cat urls.txt | getghrel -list",
+			"\tExample: echo 'https://github.com/sharkdp/bat' | getghrel -list",
+			"\tExample: echo 'sharkdp/bat' | getghrel -list",
+			"",
+			"  [light_cyan]-rank[reset]",
+			"",
+			"\t With -list, print every asset of the release ranked by score,",
+			"\t with the reasons for each score. The best asset is marked with '*'.\n",
+			"\t Example: echo 'sharkdp/bat' | getghrel -list -rank",
+			"",
+			"  [light_cyan]-policy[reset]",
+			"",
+			"\t Which releases -list picks: stable-only, allow-prerelease or nightly (default: stable-only)",
+			"\t stable-only never picks a prerelease (flagged on github, or tagged like 'v1.2.0-rc1'),",
+			"\t allow-prerelease picks prereleases but not nightly builds, and nightly picks any release.",
+			"\t A line can have its own policy: 'neovim/neovim@nightly'.\n",
+			"\t Example: cat urls.txt | getghrel -list -policy allow-prerelease",
 			"",
 			"  [light_cyan]-con[reset]",
 			"",
@@ -80,6 +148,116 @@ func ParseFlags() options {
 			"\t Specify a temporary directory to download/extract the binaries\n",
 			"\t Example: cat releases.txt | getghrel -download -tempdir '/tmp/test'",
 			"",
+			"  [light_cyan]-os[reset] and [light_cyan]-arch[reset]",
+			"",
+			"\t List/download for another OS and/or Architecture",
+			"\t Default is the OS and Architecture getghrel is running on.\n",
+			"\t Example: cat urls.txt | getghrel -list -os linux -arch arm64",
+			"",
+			"  [light_cyan]-target[reset]",
+			"",
+			"\t List/download for an os/arch pair, can be repeated",
+			"\t With multiple targets, the binaries of each target are kept",
+			"\t in their own directory inside tempdir, for e.g '/tmp/getghrel/linux-arm64'.\n",
+			"\t Example: cat urls.txt | getghrel -list -target linux/amd64 -target linux/arm64 | getghrel -download -target linux/amd64 -target linux/arm64",
+			"",
+			"  [light_cyan]-libc[reset]",
+			"",
+			"\t Choose the libc of the Linux assets: gnu, musl or any",
+			"\t Default is the libc getghrel is running on (for e.g musl on Alpine),",
+			"\t and any for other targets.",
+			"\t gnu prefers gnu builds, but accepts musl and static builds.",
+			"\t musl prefers musl builds, accepts static builds and rejects gnu builds.\n",
+			"\t Example: cat urls.txt | getghrel -list -libc musl",
+			"",
+			"  [light_cyan]-thin[reset]",
+			"",
+			"\t Reduce universal (fat) macOS binaries to the slice of the target",
+			"\t architecture, to save disk space.\n",
+			"\t Example: cat releases.txt | getghrel -download -thin",
+			"",
+			"  [light_cyan]-keep[reset]",
+			"",
+			"\t Comma separated list of the kinds of files to keep after the extraction:",
+			"\t binary, appimage and/or script (any file starting with a shebang like '#!/bin/sh')",
+			"\t Default is 'binary,appimage'.\n",
+			"\t Example: cat releases.txt | getghrel -download -keep binary,script",
+			"",
+			"  [light_cyan]-layout[reset]",
+			"",
+			"\t How the archives are extracted: flat or tree (default: flat)",
+			"\t flat extracts every file inside tempdir and keeps only the binaries.",
+			"\t tree keeps the directories of the archive in tempdir/<owner>_<repo>/<version>,",
+			"\t with all the files a tool needs (lib/, share/, runtime/...),",
+			"\t and exposes the binaries with symlinks in tempdir/bin.\n",
+			"\t Example: echo 'helix-editor/helix' | getghrel -list | getghrel -download -layout tree",
+			"",
+			"  [light_cyan]-maxsize[reset] and [light_cyan]-maxentries[reset]",
+			"",
+			"\t Limit the size (in MB) and the number of entries extracted from each archive",
+			"\t to protect against decompression bombs (default: 2048 MB and 50000 entries, 0 means no limit).",
+			"\t Archives going over a limit are not extracted.\n",
+			"\t Example: cat releases.txt | getghrel -download -maxsize 512 -maxentries 1000",
+			"",
+			"  [light_cyan]-maxdepth[reset]",
+			"",
+			"\t How many archives nested inside each other are extracted (default: 3).",
+			"\t A tar.zst inside a zip is 2, deeper archives are left as they are.\n",
+			"\t Example: cat releases.txt | getghrel -download -maxdepth 1",
+			"",
+			"  [light_cyan]-install[reset]",
+			"",
+			"\t Move the binaries kept after the extraction into -installdir, then remove tempdir.",
+			"\t A binary with the same name in -installdir is replaced if getghrel installed it,",
+			"\t see -overwrite for the other ones.",
+			"\t With -layout tree, the tree of each asset is moved to -datadir/<owner>_<repo>/<version>",
+			"\t and its executables are linked in -installdir.\n",
+			"\t Example: cat releases.txt | getghrel -download -install",
+			"",
+			"  [light_cyan]-installdir[reset] and [light_cyan]-datadir[reset]",
+			"",
+			"\t Where -install puts the binaries (default: ~/.local/bin)",
+			"\t and the trees of -layout tree (default: ~/.local/share/getghrel).\n",
+			"\t Example: cat releases.txt | getghrel -download -install -installdir /usr/local/bin",
+			"",
+			"  [light_cyan]-rename[reset]",
+			"",
+			"\t Install the binaries without their version and platform: tool-v1.2-linux-amd64 becomes tool.\n",
+			"\t Example: cat releases.txt | getghrel -download -install -rename",
+			"",
+			"  [light_cyan]-overwrite[reset]",
+			"",
+			"\t Replace the files of -installdir that getghrel didn't install.",
+			"\t Without it, an asset whose binary would replace one of them is not installed.\n",
+			"\t Example: cat releases.txt | getghrel -download -install -overwrite",
+			"",
+			"  [light_cyan]-dryrun[reset]",
+			"",
+			"\t Print what -install would install, without installing anything.\n",
+			"\t Example: cat releases.txt | getghrel -download -install -dryrun",
+			"",
+			"  [light_cyan]-trustedkeys[reset]",
+			"",
+			"\t Verify the signatures of the assets with the keys of a trusted-keys file:",
+			"\t minisign (.minisig), gpg (.asc, .sig), cosign (.sig) and cosign bundles (.bundle),",
+			"\t of the asset itself or of the checksum file that verified it.",
+			"\t An asset whose signature doesn't verify is not extracted.",
+			"\t Nothing is fetched, keyless signatures are verified against the pinned sigstore roots.\n",
+			"\t Example: cat releases.txt | getghrel -download -trustedkeys ~/.config/getghrel/keys.yaml",
+			"",
+			"  [light_cyan]-require-signature[reset]",
+			"",
+			"\t With -trustedkeys, refuse the assets that are not signed with a trusted key.\n",
+			"\t Example: cat releases.txt | getghrel -download -trustedkeys keys.yaml -require-signature",
+			"",
+			"  [light_cyan]-attestation[reset]",
+			"",
+			"\t With -trustedkeys, verify the github attestation (SLSA provenance) of each asset,",
+			"\t signed by a github workflow, against the pinned sigstore roots.",
+			"\t An asset without one, or built from another repo (a fork) or by a workflow",
+			"\t of another repo (unless the trusted-keys file names it), is refused.\n",
+			"\t Example: cat releases.txt | getghrel -download -trustedkeys keys.yaml -attestation",
+			"",
 			"  [light_cyan]-version[reset]",
 			"\t Print version\n",
 			"",
@@ -93,14 +271,238 @@ func ParseFlags() options {
 	opts := options{}
 	flag.BoolVar(&opts.Download, "download", false, "")
 	flag.BoolVar(&opts.List, "list", false, "")
+	flag.BoolVar(&opts.Rank, "rank", false, "")
+	flag.StringVar(&opts.Policy, "policy", "stable-only", "")
 	flag.BoolVar(&opts.SkipExtraction, "skipextraction", false, "")
 	flag.IntVar(&opts.Concurrency, "con", 2, "")
 	default_ghtoken := os.Getenv("GITHUB_TOKEN")
 	flag.StringVar(&opts.GHToken, "ghtoken", default_ghtoken, "")
-	flag.StringVar(&opts.TempDir, "tempdir", "/tmp/getghrel", "")
+	default_tempdir := "/tmp/getghrel"
+	if runtime.GOOS == "windows" {
+		default_tempdir = filepath.Join(os.TempDir(), "getghrel")
+	}
+	flag.StringVar(&opts.TempDir, "tempdir", default_tempdir, "")
 	flag.BoolVar(&opts.Version, "version", false, "")
+	flag.StringVar(&opts.OS, "os", "", "")
+	flag.StringVar(&opts.Arch, "arch", "", "")
+	flag.Var(&opts.Targets, "target", "")
+	flag.StringVar(&opts.Libc, "libc", "auto", "")
+	flag.BoolVar(&opts.Thin, "thin", false, "")
+	flag.StringVar(&opts.Keep, "keep", "binary,appimage", "")
+	flag.StringVar(&opts.Layout, "layout", "flat", "")
+	flag.Int64Var(&opts.MaxSize, "maxsize", utils.DefaultExtractOptions.MaxSize>>20, "")
+	flag.IntVar(&opts.MaxEntries, "maxentries", utils.DefaultExtractOptions.MaxEntries, "")
+	flag.IntVar(&opts.MaxDepth, "maxdepth", utils.DefaultExtractOptions.MaxDepth, "")
+	flag.BoolVar(&opts.Install, "install", false, "")
+	flag.StringVar(&opts.InstallDir, "installdir", defaultInstallDir(), "")
+	flag.StringVar(&opts.DataDir, "datadir", defaultDataDir(), "")
+	flag.BoolVar(&opts.Rename, "rename", false, "")
+	flag.BoolVar(&opts.Overwrite, "overwrite", false, "")
+	flag.BoolVar(&opts.DryRun, "dryrun", false, "")
+	flag.StringVar(&opts.TrustedKeys, "trustedkeys", "", "")
+	flag.BoolVar(&opts.RequireSig, "require-signature", false, "")
+	flag.BoolVar(&opts.Attestation, "attestation", false, "")
 
 	flag.Parse()
 
 	return opts
 }
+
+// commandOptions are the flags of the getghrel commands, like getghrel which
+type commandOptions struct {
+	DataDir    string
+	GHToken    string
+	DryRun     bool
+	JSON       bool
+	File       string
+	InstallDir string
+	Overwrite  bool
+	Locked     bool
+	// -trustedkeys, -require-signature and -attestation
+	TrustedKeys string
+	RequireSig  bool
+	Attestation bool
+	Args        []string
+}
+
+// usage of each command and the flags it has besides -datadir
+var commands = map[string]struct {
+	usage []string
+	flags []string
+}{
+	"which": {
+		usage: []string{
+			"Print the repo, version and asset an installed binary came from",
+			"",
+			"[light_cyan]Usage:[reset]",
+			"",
+			"  getghrel which bat",
+			"  getghrel which ~/.local/bin/bat",
+		},
+	},
+	"upgrade": {
+		usage: []string{
+			"Check every installed tool for a newer release, and upgrade the ones that have one",
+			"",
+			"[light_cyan]Usage:[reset]",
+			"",
+			"  getghrel upgrade -dryrun",
+			"  getghrel upgrade",
+			"  getghrel upgrade sharkdp/bat junegunn/fzf",
+		},
+		flags: []string{"ghtoken", "dryrun", "trustedkeys", "require-signature", "attestation"},
+	},
+	"installed": {
+		usage: []string{
+			"List the tools installed by getghrel",
+			"",
+			"[light_cyan]Usage:[reset]",
+			"",
+			"  getghrel installed",
+			"  getghrel installed -json",
+		},
+		flags: []string{"json"},
+	},
+	"uninstall": {
+		usage: []string{
+			"Remove the files of installed tools, by repo or by binary name.",
+			"Files modified since they were installed are not removed.",
+			"",
+			"[light_cyan]Usage:[reset]",
+			"",
+			"  getghrel uninstall sharkdp/bat",
+			"  getghrel uninstall bat hx",
+		},
+		flags: []string{"dryrun"},
+	},
+	"sync": {
+		usage: []string{
+			"Install the tools listed in a tools file, at the versions it pins",
+			"",
+			"[light_cyan]Usage:[reset]",
+			"",
+			"  getghrel sync -dryrun",
+			"  getghrel sync -f ~/dotfiles/tools.yaml",
+			"  getghrel sync -locked",
+		},
+		flags: []string{"file", "installdir", "overwrite", "ghtoken", "dryrun", "locked", "trustedkeys", "require-signature", "attestation"},
+	},
+	"lock": {
+		usage: []string{
+			"Pin every tool of a tools file to an exact release, and the tag, url,",
+			"size and sha256 of its asset for each target, in the lockfile next to it",
+			"(tools.yaml -> tools.lock). getghrel sync -locked installs exactly those assets.",
+			"",
+			"[light_cyan]Usage:[reset]",
+			"",
+			"  getghrel lock",
+			"  getghrel lock -f ~/dotfiles/tools.yaml",
+			"  getghrel lock sharkdp/bat   # move it to its latest matching release",
+		},
+		flags: []string{"file", "ghtoken"},
+	},
+}
+
+// help of the flags of the commands
+var commandFlags = map[string][]string{
+	"datadir": {
+		"  [light_cyan]-datadir[reset]",
+		"\t Where the manifest of the installed binaries is (default: ~/.local/share/getghrel)",
+	},
+	"ghtoken": {
+		"  [light_cyan]-ghtoken[reset]",
+		"\t Github token, read from the GITHUB_TOKEN environment variable by default",
+	},
+	"dryrun": {
+		"  [light_cyan]-dryrun[reset]",
+		"\t Print what would be done, without doing it",
+	},
+	"json": {
+		"  [light_cyan]-json[reset]",
+		"\t Print JSON instead of a table",
+	},
+	"file": {
+		"  [light_cyan]-f, -file[reset]",
+		"\t The tools file (default: tools.yaml)",
+	},
+	"locked": {
+		"  [light_cyan]-locked[reset]",
+		"\t Install the assets pinned in the lockfile, and fail on any size or sha256 mismatch",
+	},
+	"trustedkeys": {
+		"  [light_cyan]-trustedkeys[reset]",
+		"\t Verify the signatures of the assets with the keys of a trusted-keys file",
+	},
+	"require-signature": {
+		"  [light_cyan]-require-signature[reset]",
+		"\t With -trustedkeys, refuse the assets that are not signed with a trusted key",
+	},
+	"attestation": {
+		"  [light_cyan]-attestation[reset]",
+		"\t With -trustedkeys, refuse the assets without a github attestation built by their own repo",
+	},
+	"installdir": {
+		"  [light_cyan]-installdir[reset]",
+		"\t Where the binaries are installed (default: ~/.local/bin)",
+	},
+	"overwrite": {
+		"  [light_cyan]-overwrite[reset]",
+		"\t Replace the files of -installdir that getghrel didn't install",
+	},
+}
+
+// Return true if name is a getghrel command
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// Parse the flags of the command name, args are the arguments following it
+func ParseCommand(name string, args []string) commandOptions {
+	command := commands[name]
+	flags := append([]string{"datadir"}, command.flags...)
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		h := append([]string{""}, command.usage...)
+		h = append(h, "", "[light_cyan]Flags:[reset]", "")
+		for _, f := range flags {
+			h = append(h, commandFlags[f]...)
+			h = append(h, "")
+		}
+		colorstring.Println(strings.Join(h, "\n"))
+	}
+
+	opts := commandOptions{}
+	for _, f := range flags {
+		switch f {
+		case "datadir":
+			fs.StringVar(&opts.DataDir, "datadir", defaultDataDir(), "")
+		case "ghtoken":
+			fs.StringVar(&opts.GHToken, "ghtoken", os.Getenv("GITHUB_TOKEN"), "")
+		case "dryrun":
+			fs.BoolVar(&opts.DryRun, "dryrun", false, "")
+		case "json":
+			fs.BoolVar(&opts.JSON, "json", false, "")
+		case "file":
+			fs.StringVar(&opts.File, "f", "tools.yaml", "")
+			fs.StringVar(&opts.File, "file", "tools.yaml", "")
+		case "locked":
+			fs.BoolVar(&opts.Locked, "locked", false, "")
+		case "installdir":
+			fs.StringVar(&opts.InstallDir, "installdir", defaultInstallDir(), "")
+		case "overwrite":
+			fs.BoolVar(&opts.Overwrite, "overwrite", false, "")
+		case "trustedkeys":
+			fs.StringVar(&opts.TrustedKeys, "trustedkeys", "", "")
+		case "require-signature":
+			fs.BoolVar(&opts.RequireSig, "require-signature", false, "")
+		case "attestation":
+			fs.BoolVar(&opts.Attestation, "attestation", false, "")
+		}
+	}
+	fs.Parse(args)
+	opts.Args = fs.Args()
+
+	return opts
+}
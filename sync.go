@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/kavishgr/getghrel/config"
+	"github.com/kavishgr/getghrel/github"
+	"github.com/kavishgr/getghrel/manifest"
+	"github.com/kavishgr/getghrel/options"
+	"github.com/kavishgr/getghrel/utils"
+)
+
+// a tool of the tools file and the release it resolved to
+type syncCheck struct {
+	tool     config.Tool
+	platform utils.Platform
+	tag      string
+	asset    utils.Candidate
+	// with -locked, the size and sha256 of the asset in the lockfile
+	checksum *github.Checksum
+	// the version installed now, if any
+	installed string
+	// why the tool is not installed, empty if it is
+	status string
+}
+
+// Resolve the release and asset of a tool of the tools file for the host,
+// or read them from the lockfile when there's one (-locked)
+func checkSync(ghtoken string, c *config.Config, lock *config.Lock, t config.Tool, host utils.Platform, m *manifest.Manifest) syncCheck {
+	check := syncCheck{tool: t}
+
+	p, ok := c.Platform(t, host)
+	if !ok {
+		check.status = "skipped, not a target"
+		return check
+	}
+	check.platform = p
+
+	if lock != nil {
+		tag, locked, err := lock.Asset(t, p)
+		if err != nil {
+			check.status = err.Error()
+			return check
+		}
+		check.tag = tag
+		check.asset = utils.Candidate{Asset: utils.Asset{Name: locked.Asset, URL: locked.URL, Size: locked.Size, SHA256: locked.SHA256}}
+		check.checksum = &github.Checksum{Size: locked.Size, SHA256: locked.SHA256}
+	} else {
+		release, err := github.ResolveRelease(ghtoken, t.Repo, t.Version, github.Policy(t.Policy))
+		if err != nil {
+			check.status = err.Error()
+			return check
+		}
+		if release.Tag == "" {
+			check.status = "no release found"
+			return check
+		}
+		check.tag = release.Tag
+
+		asset, found := release.Asset(p, t.Asset)
+		if !found {
+			check.status = "no asset for " + p.String()
+			return check
+		}
+		check.asset = asset
+	}
+
+	for _, installed := range m.Tools {
+		if installed.Repo == t.Repo && installed.Platform == p.String() {
+			check.installed = installed.Tag
+			if installed.Tag == check.tag && installed.Asset == check.asset.Name {
+				check.status = "up to date"
+			}
+		}
+	}
+	return check
+}
+
+// Return the platform with the libc its linux assets are resolved for,
+// the same for getghrel sync and getghrel lock: the libc of the host
+// for the host, any libc for the other targets
+func withLibc(p utils.Platform) (utils.Platform, error) {
+	var err error
+	p.Libc, err = utils.TargetLibc(p, "auto")
+	return p, err
+}
+
+// Print the version and asset every tool resolved to
+func printSync(checks []syncCheck) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tVERSION\tASSET\tSTATUS")
+	for _, c := range checks {
+		status := c.status
+		switch {
+		case status != "":
+		case c.installed != "":
+			status = "upgrade from " + c.installed
+		default:
+			status = "install"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.tool.Repo, c.tag, c.asset.Name, status)
+	}
+	w.Flush()
+}
+
+/*
+- getghrel sync -f tools.yaml: resolve every tool of the tools file
+for the platform getghrel is running on, and install the ones that
+are not installed at the resolved version.
+
+- A tool is resolved to its pinned tag, the highest release satisfying
+its semver constraint, or the latest release. Its asset is the best one
+for the host among the assets matching its asset pattern.
+
+- Executables are renamed like -rename does, or to the rename of the tool.
+Tools installed but missing from the file are left alone.
+
+- With -locked, the releases are not resolved: every tool is installed
+from the asset pinned for the host in the lockfile (see getghrel lock),
+and an asset whose size or sha256 doesn't match the lockfile is not installed.
+*/
+func syncCommand(args []string) int {
+	opts := options.ParseCommand("sync", args)
+	if opts.GHToken == "" {
+		fmt.Println("GITHUB_TOKEN environment variable is not found.")
+		fmt.Println("Nor is -ghtoken provided on the command line.")
+		return 1
+	}
+
+	c, err := config.Load(opts.File)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	var lock *config.Lock
+	if opts.Locked {
+		if lock, err = config.LoadLock(config.LockPath(opts.File)); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	m, err := manifest.Load(manifest.Path(opts.DataDir))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	keys, err := trustedKeys(opts.TrustedKeys, opts.RequireSig, opts.Attestation)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	host, err := withLibc(utils.HostPlatform())
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	checks := make([]syncCheck, len(c.Tools))
+	var jobs sync.WaitGroup
+	sem := make(chan struct{}, upgradeConcurrency)
+	for i, t := range c.Tools {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			checks[i] = checkSync(opts.GHToken, c, lock, t, host, m)
+		}()
+	}
+	jobs.Wait()
+
+	printSync(checks)
+	if opts.DryRun {
+		return 0
+	}
+
+	status := 0
+	for _, check := range checks {
+		switch check.status {
+		case "":
+		case "up to date", "skipped, not a target":
+			continue
+		default:
+			status = 1
+			continue
+		}
+
+		fmt.Printf("\nInstalling %s %s\n", check.tool.Repo, check.tag)
+		rel := releaseAsset{
+			repo:     check.tool.Repo,
+			tag:      check.tag,
+			asset:    check.asset,
+			platform: check.platform,
+			checksum: check.checksum,
+		}
+		err := installRelease(rel, installOptions{
+			dir:              opts.InstallDir,
+			datadir:          opts.DataDir,
+			rename:           true,
+			overwrite:        opts.Overwrite,
+			name:             check.tool.Rename,
+			tree:             check.tool.Layout == "tree",
+			policy:           github.Policy(check.tool.Policy),
+			version:          check.tool.Version,
+			pattern:          check.tool.Asset,
+			keys:             keys,
+			requireSignature: opts.RequireSig,
+			attestation:      opts.Attestation,
+		}, opts.GHToken)
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", check.tool.Repo, err)
+			status = 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/kavishgr/getghrel/github"
+	"github.com/kavishgr/getghrel/manifest"
+	"github.com/kavishgr/getghrel/options"
+	"github.com/kavishgr/getghrel/utils"
+)
+
+// an installed tool and the latest release found for it
+type upgradeCheck struct {
+	tool     manifest.Tool
+	platform utils.Platform
+	tag      string
+	asset    utils.Candidate
+	// why the tool is not upgraded, empty if it is
+	status string
+}
+
+// how many repos are checked at once
+const upgradeConcurrency = 4
+
+/*
+- Resolve the release of an installed tool the way it was installed:
+its pinned tag or semver constraint and its asset pattern (from a tools file),
+or the latest release. Its best asset is picked for the platform it was installed for.
+
+- The tool is only upgraded when the release is a newer version by semver,
+never to an older or an unrelated tag.
+*/
+func checkUpgrade(ghtoken string, tool manifest.Tool) upgradeCheck {
+	check := upgradeCheck{tool: tool}
+	if tool.Repo == "" {
+		check.status = "not from a github release"
+		return check
+	}
+
+	p, err := utils.ParsePlatform(tool.Platform)
+	if err != nil {
+		check.status = err.Error()
+		return check
+	}
+	p.Libc = tool.Libc
+	check.platform = p
+
+	policy, err := github.ParsePolicy(tool.Policy)
+	if err != nil {
+		check.status = err.Error()
+		return check
+	}
+	release, err := github.ResolveRelease(ghtoken, tool.Repo, tool.Version, policy)
+	if err != nil {
+		check.status = err.Error()
+		return check
+	}
+	check.tag = release.Tag
+	asset, found := release.Asset(p, tool.AssetPattern)
+	check.asset = asset
+	switch {
+	case release.Tag == "":
+		check.status = "no release found"
+	case release.Tag == tool.Tag:
+		check.status = "up to date"
+	case !github.NewerTag(release.Tag, tool.Tag):
+		check.status = fmt.Sprintf("up to date (%s is not newer)", release.Tag)
+	case !found:
+		check.status = "no asset for " + tool.Platform
+	}
+	return check
+}
+
+// Print the current -> new version of every tool checked
+func printUpgrades(checks []upgradeCheck) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tPLATFORM\tCURRENT\t\tNEW")
+	for _, c := range checks {
+		name := c.tool.Repo
+		if name == "" {
+			name = c.tool.Asset
+		}
+		if c.status != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\t\t%s\n", name, c.tool.Platform, c.tool.Tag, c.status)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t→\t%s\n", name, c.tool.Platform, c.tool.Tag, c.tag)
+	}
+	w.Flush()
+}
+
+/*
+- Remove the files and links of an old version of a tool
+that the new version didn't replace.
+
+- A file that was modified since it was installed is kept, with a warning,
+and so is the directory it's in (see pruneDir).
+*/
+func removeOldVersion(old, current manifest.Tool) {
+	for _, f := range old.Files {
+		if current.Owns(f.Path) {
+			continue
+		}
+		switch fileStatus(f) {
+		case "unchanged":
+			os.Remove(f.Path)
+		case "missing":
+		default:
+			fmt.Printf("Warning: %s was modified since it was installed, not removed\n", f.Path)
+		}
+	}
+	for _, l := range old.Links {
+		if fi, err := os.Lstat(l); err == nil && fi.Mode()&os.ModeSymlink != 0 && !current.Owns(l) {
+			os.Remove(l)
+		}
+	}
+	if old.Dir != "" && old.Dir != current.Dir {
+		pruneDir(old.Dir)
+	}
+}
+
+// Download the new version of a tool and install it the way the current one was,
+// then remove what's left of the current one. opts has the datadir and the signature settings
+func upgradeTool(check upgradeCheck, opts installOptions, ghtoken string) error {
+	tool := check.tool
+
+	installDir := tool.InstallDir
+	if installDir == "" && len(tool.Files) > 0 {
+		installDir = filepath.Dir(tool.Files[0].Path)
+	}
+	rel := releaseAsset{repo: tool.Repo, tag: check.tag, asset: check.asset, platform: check.platform}
+	opts.dir = installDir
+	opts.rename, opts.name = tool.Rename, tool.Name
+	opts.tree = tool.Dir != ""
+	opts.thin, opts.keep = tool.Thin, tool.Keep
+	opts.policy = github.Policy(tool.Policy)
+	opts.version, opts.pattern = tool.Version, tool.AssetPattern
+	return installRelease(rel, opts, ghtoken)
+}
+
+/*
+- getghrel upgrade [owner/repo...]: check every installed tool
+(or the ones given) for a newer release, print a current -> new version table,
+and upgrade only the tools that have one.
+
+- The release and its asset are found the way -list finds them,
+for the platform each tool was installed for, with the release policy
+it was installed with (stable-only unless a tools file said otherwise).
+A tool installed from a tools file keeps to its pinned tag or semver constraint
+and its asset pattern, and a tool is only upgraded to a newer version (see checkUpgrade).
+The new version is installed the way the current one was
+(same directory, layout, -rename, -thin and -keep).
+*/
+func upgradeCommand(args []string) int {
+	opts := options.ParseCommand("upgrade", args)
+	if opts.GHToken == "" {
+		fmt.Println("GITHUB_TOKEN environment variable is not found.")
+		fmt.Println("Nor is -ghtoken provided on the command line.")
+		return 1
+	}
+
+	m, err := manifest.Load(manifest.Path(opts.DataDir))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	keys, err := trustedKeys(opts.TrustedKeys, opts.RequireSig, opts.Attestation)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	var tools []manifest.Tool
+	for _, t := range m.Tools {
+		if len(opts.Args) == 0 || slices.Contains(opts.Args, t.Repo) {
+			tools = append(tools, t)
+		}
+	}
+	if len(tools) == 0 {
+		fmt.Println("No installed tools to upgrade")
+		return 0
+	}
+
+	checks := make([]upgradeCheck, len(tools))
+	var jobs sync.WaitGroup
+	sem := make(chan struct{}, upgradeConcurrency)
+	for i, t := range tools {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			checks[i] = checkUpgrade(opts.GHToken, t)
+		}()
+	}
+	jobs.Wait()
+
+	printUpgrades(checks)
+	if opts.DryRun {
+		return 0
+	}
+
+	status := 0
+	for _, c := range checks {
+		if c.status != "" {
+			continue
+		}
+		fmt.Printf("\nUpgrading %s: %s → %s\n", c.tool.Repo, c.tool.Tag, c.tag)
+		installOpts := installOptions{datadir: opts.DataDir, keys: keys, requireSignature: opts.RequireSig, attestation: opts.Attestation}
+		if err := upgradeTool(c, installOpts, opts.GHToken); err != nil {
+			fmt.Printf("Error: %s: %v\n", c.tool.Repo, err)
+			status = 1
+		}
+	}
+	return status
+}
@@ -2,94 +2,514 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/mholt/archiver/v4"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
-func Extractor(src, tempdir string) error {
-
-	supportFormat := []string{
-		"rar",
-		"zip",
-		"tar",
-		"gz",
-		"br",
-		"sz",
-		"zz",
-		"zst",
-		"bz2",
-		"7z",
-		"xz",
-		"tar",
-		"tbz",
-		"tar.xz",
-		"tar.gz",
-		"gzip",
-	}
-
-	var isSupported = false
-
-	for _, format := range supportFormat {
-		if strings.HasSuffix(src, format) {
-			isSupported = true
-			break // stop the loop and jump to the next instruction
+// ExtractOptions limit what a single archive can extract,
+// to protect against decompression bombs (0 means no limit)
+type ExtractOptions struct {
+	// maximum number of bytes extracted from an archive
+	MaxSize int64
+	// maximum number of entries (files, directories and links) in an archive
+	MaxEntries int
+	// how many archives nested inside each other are extracted,
+	// for e.g a tar.zst inside a zip is 2 (at least 1)
+	MaxDepth int
+}
+
+// DefaultExtractOptions are the limits of -maxsize, -maxentries and -maxdepth by default
+var DefaultExtractOptions = ExtractOptions{MaxSize: 2048 << 20, MaxEntries: 50000, MaxDepth: 3}
+
+/*
+- Extract src inside tempdir and return the path of every extracted file.
+The directories of the archive are dropped, every file ends up in tempdir itself.
+
+- A single compressed file (tool.gz) is decompressed as tool,
+and the archives found inside the archive are extracted as well (see unpack).
+
+- If src is not an archive (a binary), nothing is extracted.
+*/
+func Extractor(src, tempdir string, opts ExtractOptions) ([]string, error) {
+	e := &extraction{opts: opts}
+	if _, err := e.run(src, tempdir); err != nil {
+		return nil, err
+	}
+	return e.files, nil
+}
+
+/*
+- Extract src inside dest, keeping the directory tree of the archive,
+and return the path of every extracted file.
+
+- When every entry of the archive is inside a single top-level directory
+(like "helix-24.03-x86_64-linux/"), that directory is stripped.
+
+- If src is not an archive (a binary), it's moved inside dest.
+*/
+func ExtractTree(src, dest string, opts ExtractOptions) ([]string, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+
+	ok, err := (&extraction{tree: true, opts: opts}).run(src, dest)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		binpath := filepath.Join(dest, filepath.Base(src))
+		return []string{binpath}, os.Rename(src, binpath)
+	}
+
+	if err := stripTopDir(dest); err != nil {
+		return nil, err
+	}
+
+	// paths have changed if the top-level directory was stripped
+	var files []string
+	err = filepath.WalkDir(dest, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return err
+	})
+	return files, err
+}
+
+// move everything inside the single top-level directory of dest (if there's one) into dest
+func stripTopDir(dest string) error {
+	entries, err := os.ReadDir(dest)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return err
+	}
+
+	// the directory may contain a file with its own name, for e.g tool/tool
+	top := filepath.Join(dest, ".getghrel-strip")
+	if err := os.Rename(filepath.Join(dest, entries[0].Name()), top); err != nil {
+		return err
+	}
+
+	children, err := os.ReadDir(top)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := os.Rename(filepath.Join(top, child.Name()), filepath.Join(dest, child.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Remove(top)
+}
+
+// extraction is the state of the extraction of a single asset,
+// the downloaded archive and every archive nested inside it
+type extraction struct {
+	dest     string // directory of the archive being extracted
+	realDest string // directory of the asset with its symlinks resolved
+	tree     bool   // keep the directories of the archive
+	opts     ExtractOptions
+
+	size    int64
+	entries int
+	files   []string
+	links   []string
+}
+
+// Return true if p is dest or inside it
+func within(dest, p string) bool {
+	rel, err := filepath.Rel(dest, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Return the path of an archive entry inside dest,
+// or an error if it's absolute or would escape dest (zip slip)
+func safeJoin(dest, name string) (string, error) {
+	native := filepath.FromSlash(name)
+	if filepath.IsAbs(native) || strings.HasPrefix(name, "/") || filepath.VolumeName(native) != "" {
+		return "", fmt.Errorf("%s: absolute path in archive", name)
+	}
+	p := filepath.Join(dest, native)
+	if !within(dest, p) || p == filepath.Clean(dest) {
+		return "", fmt.Errorf("%s: path escapes the extraction directory", name)
+	}
+	return p, nil
+}
+
+// verify the directory p is written in doesn't lead outside dest through a symlink,
+// before creating it
+func (e *extraction) checkParent(p string) error {
+	dir := filepath.Dir(p)
+
+	// the deepest directory that exists, the missing ones are created inside it
+	existing := dir
+	for {
+		if _, err := os.Lstat(existing); err == nil || existing == e.dest {
+			break
 		}
+		existing = filepath.Dir(existing)
+	}
+
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	if !within(e.realDest, real) {
+		return fmt.Errorf("%s: path escapes the extraction directory through a symlink", p)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// never write through a link left by a previous entry
+	if fi, err := os.Lstat(p); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		return os.Remove(p)
+	}
+	return nil
+}
+
+// copy src to dst without going over the size limit of the archive
+func (e *extraction) copy(dst io.Writer, src io.Reader) error {
+	if e.opts.MaxSize > 0 {
+		src = io.LimitReader(src, e.opts.MaxSize-e.size+1)
+	}
+	n, err := io.Copy(dst, src)
+	e.size += n
+	if e.opts.MaxSize > 0 && e.size > e.opts.MaxSize {
+		return fmt.Errorf("more than %d bytes extracted, the limit (-maxsize)", e.opts.MaxSize)
+	}
+	return err
+}
+
+// handle a single entry of the archive
+func (e *extraction) handle(f archiver.File) error {
+	e.entries++
+	if e.opts.MaxEntries > 0 && e.entries > e.opts.MaxEntries {
+		return fmt.Errorf("more than %d entries in the archive, the limit (-maxentries)", e.opts.MaxEntries)
+	}
 
+	name := f.NameInArchive
+	if !e.tree {
+		name = f.Name()
 	}
 
-	if !isSupported {
-		// check if the file has no suffix at all
-		if strings.IndexByte(src, '.') == -1 {
-			// return fmt.Errorf("%s has no supported suffix", src)
-			// just a binary, not an archive, or compressed archive
+	if f.IsDir() {
+		if !e.tree {
 			return nil
-			// do something else because it'a a regular file
 		}
-		return fmt.Errorf("%s is not supported", src)
+		p, err := safeJoin(e.dest, name)
+		if err != nil {
+			return err
+		}
+		if err := e.checkParent(p); err != nil {
+			return err
+		}
+		return os.MkdirAll(p, 0755)
+	}
+
+	p, err := safeJoin(e.dest, name)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case f.Mode()&fs.ModeSymlink != 0:
+		return e.symlink(f, p)
+	case f.LinkTarget != "":
+		return e.hardlink(f, p)
+	case !f.Mode().IsRegular():
+		// devices, fifos and sockets are not extracted
+		return nil
 	}
 
-	reader, err := os.Open(src)
-	// fullpath, _ := filepath.Abs(src)
+	// create a new file with the same name as the extracted file
+	// f.Open() returns an io.ReadCloser
+	content, err := f.Open()
 	if err != nil {
 		return err
 	}
+	defer content.Close()
+
+	return e.write(p, content, f.Mode().Perm())
+}
+
+// write a file, keeping the permissions of the archive so executables stay executable
+func (e *extraction) write(p string, content io.Reader, perm fs.FileMode) error {
+	if err := e.checkParent(p); err != nil {
+		return err
+	}
 
-	format, input, err := archiver.Identify(src, reader)
+	newFile, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm|0600)
 	if err != nil {
 		return err
-	} else {
-		if ex, ok := format.(archiver.Extractor); ok {
-			// fmt.Println("Extracting ", src)
-			ex.Extract(context.Background(), input, nil, func(ctx context.Context, f archiver.File) error {
-				stat, _ := f.Stat()
-				// fmt.Println(stat.Name())
-
-				// create a new file with the same name as the extracted file
-				// f.Open() returns an io.ReadCloser
-				content, _ := f.Open()
-				defer content.Close()
-
-				newFilePath := filepath.Join(tempdir, stat.Name())
-				newFile, err := os.Create(newFilePath)
-				if err != nil {
-					return err
-				}
-				defer newFile.Close()
-
-				// copy the contents of the extracted file to the new file
-
-				_, err = io.Copy(newFile, content)
-				if err != nil {
-					return err
-				}
-				return nil
-			})
+	}
+	defer newFile.Close()
+	e.files = append(e.files, p)
+
+	// copy the contents of the extracted file to the new file
+	return e.copy(newFile, content)
+}
+
+/*
+- Create a symlink of the archive.
 
+- With the flat layout, symlinks are not extracted.
+With the tree layout, a symlink is refused if it's absolute
+or points outside dest. Every symlink is verified again
+once the whole archive is extracted (see verifyLinks).
+*/
+func (e *extraction) symlink(f archiver.File, p string) error {
+	if !e.tree {
+		return nil
+	}
+
+	target := f.LinkTarget
+	// zip stores the target of a symlink as its content
+	if target == "" {
+		content, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		b, err := io.ReadAll(io.LimitReader(content, 4096))
+		if err != nil {
+			return err
 		}
+		target = string(b)
 	}
+
+	if filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+		return fmt.Errorf("%s: symlink to an absolute path (%s)", f.NameInArchive, target)
+	}
+	if !within(e.dest, filepath.Join(filepath.Dir(p), filepath.FromSlash(target))) {
+		return fmt.Errorf("%s: symlink points outside the extraction directory (%s)", f.NameInArchive, target)
+	}
+
+	if err := e.checkParent(p); err != nil {
+		return err
+	}
+	os.Remove(p)
+	if err := os.Symlink(filepath.FromSlash(target), p); err != nil {
+		return err
+	}
+	e.links = append(e.links, p)
 	return nil
 }
+
+// Create a hard link of the archive, as a copy of a file extracted earlier from the same archive
+func (e *extraction) hardlink(f archiver.File, p string) error {
+	name := f.LinkTarget
+	if !e.tree {
+		name = path.Base(f.LinkTarget)
+	}
+	linked, err := safeJoin(e.dest, name)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(e.files, linked) {
+		return fmt.Errorf("%s: hard link to %s, which is not in the archive", f.NameInArchive, f.LinkTarget)
+	}
+
+	src, err := os.Open(linked)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	return e.write(p, src, fi.Mode().Perm())
+}
+
+// once everything is extracted, verify every symlink resolves inside dest
+func (e *extraction) verifyLinks() error {
+	for _, link := range e.links {
+		real, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			// dangling, it can't be verified
+			os.Remove(link)
+			continue
+		}
+		if !within(e.realDest, real) {
+			return fmt.Errorf("%s: symlink resolves outside the extraction directory", link)
+		}
+	}
+	return nil
+}
+
+// remove everything extracted so far, after an error
+func (e *extraction) undo() {
+	for _, p := range append(e.files, e.links...) {
+		os.Remove(p)
+	}
+}
+
+/*
+- Unpack src inside dest and return false if src is neither
+an archive nor a compressed file.
+
+- The extraction stops at the first error: the files extracted
+so far are removed and the error is returned.
+*/
+func (e *extraction) run(src, dest string) (bool, error) {
+	var err error
+	if e.realDest, err = filepath.EvalSymlinks(dest); err != nil {
+		return false, err
+	}
+
+	ok, err := e.unpack(src, dest, 1)
+	if err == nil {
+		err = e.verifyLinks()
+	}
+	if err != nil {
+		e.undo()
+		return false, err
+	}
+	return ok, nil
+}
+
+// Return the format of the archive or the compressed file f, identified by its content.
+// nil is returned for anything else (a binary, a script...)
+func identify(f *os.File) (archiver.Format, io.Reader, error) {
+	// without a name, only the content is matched:
+	// a .tgz is a tar.gz and tool-linux-gzip is not compressed
+	format, input, err := archiver.Identify("", f)
+	if errors.Is(err, archiver.ErrNoMatch) {
+		return nil, input, nil
+	}
+	return format, input, err
+}
+
+/*
+- Unpack the archive or the compressed file src inside dir.
+depth is how many archives src is nested in, 1 for the asset itself.
+
+- An archive is extracted, and a single compressed file
+is decompressed with its suffix stripped (tool.exe.xz -> tool.exe).
+Compressed zip and 7z archives need random access
+to be read, they're decompressed first.
+
+- The archives found among the extracted files (see nested) are unpacked
+in turn, up to opts.MaxDepth. A nested archive is removed once it's unpacked.
+*/
+func (e *extraction) unpack(src, dir string, depth int) (bool, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+
+	format, input, err := identify(f)
+	if err != nil || format == nil {
+		f.Close()
+		return false, err
+	}
+
+	comp, _ := format.(archiver.Compression)
+	ex, _ := format.(archiver.Extractor)
+	if ca, ok := format.(archiver.CompressedArchive); ok {
+		switch ca.Archival.(type) {
+		case archiver.Zip, archiver.SevenZip:
+			comp, ex = ca.Compression, nil
+		}
+	}
+
+	var files []string
+	switch {
+	case ex != nil:
+		files, err = e.extract(ex, input, dir)
+	case comp != nil:
+		files, err = e.decompress(comp, input, src, dir)
+	}
+	// closed before src is replaced or removed, windows can't do it while it's open
+	f.Close()
+	if err != nil {
+		return true, err
+	}
+
+	switch {
+	// decompressed under the same name, src is replaced by its content
+	case len(files) == 1 && files[0] == src+tmpSuffix:
+		if err := os.Rename(files[0], src); err != nil {
+			return true, err
+		}
+		files[0] = src
+		e.files[len(e.files)-1] = src
+	case depth > 1:
+		e.files = slices.DeleteFunc(e.files, func(p string) bool { return p == src })
+		if err := os.Remove(src); err != nil {
+			return true, err
+		}
+	}
+
+	if depth >= e.opts.MaxDepth {
+		return true, nil
+	}
+	for _, file := range files {
+		if !e.nested(file) {
+			continue
+		}
+		if _, err := e.unpack(file, filepath.Dir(file), depth+1); err != nil {
+			return true, fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+	}
+	return true, nil
+}
+
+// Return true if a file extracted from an archive has to be unpacked as well.
+// Only names of archives are, so jars, wheels and the like are left alone,
+// and with the tree layout single compressed files (like man pages) are kept as they are
+func (e *extraction) nested(file string) bool {
+	switch kind, _ := assetKind(filepath.Base(file)); kind {
+	case "gz", "xz", "bz2", "zst":
+		return !e.tree
+	case "binary", "exe", "appimage", "script":
+		return false
+	}
+	return true
+}
+
+// extract every entry of an archive inside dir and return the files extracted
+func (e *extraction) extract(ex archiver.Extractor, input io.Reader, dir string) ([]string, error) {
+	e.dest = dir
+	first := len(e.files)
+	err := ex.Extract(context.Background(), input, nil, func(ctx context.Context, f archiver.File) error {
+		return e.handle(f)
+	})
+	return slices.Clone(e.files[first:]), err
+}
+
+// suffix of a file decompressed under the name of its compressed file, until it replaces it
+const tmpSuffix = ".getghrel-tmp"
+
+// Decompress a single compressed file inside dir.
+// Its name is the name of src without the compression suffix
+func (e *extraction) decompress(comp archiver.Compression, input io.Reader, src, dir string) ([]string, error) {
+	name := filepath.Base(src)
+	if strings.HasSuffix(strings.ToLower(name), comp.Name()) {
+		name = name[:len(name)-len(comp.Name())]
+	}
+	dst := filepath.Join(dir, name)
+	// identified by its content only, there's no suffix to strip
+	if dst == src {
+		dst += tmpSuffix
+	}
+
+	r, err := comp.OpenReader(input)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	e.dest = dir
+	if err := e.write(dst, r, 0755); err != nil {
+		return nil, err
+	}
+	return []string{dst}, nil
+}
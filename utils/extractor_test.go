@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Copy the archive name of testdata (made by testdata/gen.go) inside a new directory,
+// and return its path and the directory to extract it in, both inside root
+func setupArchive(t *testing.T, name string) (root, src, dest string) {
+	t.Helper()
+	root = t.TempDir()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = filepath.Join(root, "asset", name)
+	dest = filepath.Join(root, "dest")
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root, src, dest
+}
+
+// Return the files under dir, relative to it and sorted
+func filesUnder(t *testing.T, dir string, paths []string) []string {
+	t.Helper()
+	var rel []string
+	for _, p := range paths {
+		r, err := filepath.Rel(dir, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	slices.Sort(rel)
+	return rel
+}
+
+// Fail if anything but the asset and the extraction directory is in root,
+// or if an extraction that failed left a file behind
+func checkExtracted(t *testing.T, root, dest string, failed bool) {
+	t.Helper()
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "asset" && e.Name() != "dest" {
+			t.Errorf("%s written outside the extraction directory", e.Name())
+		}
+	}
+	if !failed {
+		return
+	}
+	filepath.WalkDir(dest, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			t.Errorf("%s left behind after the extraction failed", p)
+		}
+		return err
+	})
+}
+
+func TestExtractTree(t *testing.T) {
+	tests := []struct {
+		archive string
+		files   []string
+		wantErr bool
+	}{
+		// the top-level directory is stripped, the hard link is a copy and the symlink is kept
+		{archive: "tool.tar.gz", files: []string{"doc/README.md", "tool", "tool-1.0"}},
+		{archive: "tool.zip", files: []string{"doc/README.md", "tool"}},
+		{archive: "nested.zip", files: []string{"tool"}},
+		{archive: "tool.gz", files: []string{"tool"}},
+		{archive: "traversal.tar", wantErr: true},
+		{archive: "traversal.zip", wantErr: true},
+		{archive: "absolute.tar", wantErr: true},
+		{archive: "symlink_escape.tar", wantErr: true},
+		{archive: "symlink_escape.zip", wantErr: true},
+		{archive: "symlink_absolute.tar", wantErr: true},
+		{archive: "symlink_chain.tar", wantErr: true},
+		{archive: "symlink_resolve.tar", wantErr: true},
+		{archive: "hardlink_escape.tar", wantErr: true},
+		{archive: "hardlink_missing.tar", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.archive, func(t *testing.T) {
+			root, src, dest := setupArchive(t, tt.archive)
+			files, err := ExtractTree(src, dest, DefaultExtractOptions)
+			checkExtracted(t, root, dest, err != nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ExtractTree() = %v, want an error", filesUnder(t, dest, files))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filesUnder(t, dest, files); !slices.Equal(got, tt.files) {
+				t.Errorf("ExtractTree() = %v, want %v", got, tt.files)
+			}
+		})
+	}
+
+	t.Run("symlink", func(t *testing.T) {
+		_, src, dest := setupArchive(t, "tool.tar.gz")
+		if _, err := ExtractTree(src, dest, DefaultExtractOptions); err != nil {
+			t.Fatal(err)
+		}
+		if target, err := os.Readlink(filepath.Join(dest, "doc", "tool.md")); err != nil || target != "README.md" {
+			t.Errorf("doc/tool.md links to %q (%v), want README.md", target, err)
+		}
+	})
+}
+
+func TestExtractor(t *testing.T) {
+	// the directories of the archive are dropped, and its links with them
+	tests := []struct {
+		archive string
+		files   []string
+		wantErr bool
+	}{
+		{archive: "tool.tar.gz", files: []string{"README.md", "tool", "tool-1.0"}},
+		{archive: "tool.zip", files: []string{"README.md", "tool"}},
+		{archive: "nested.zip", files: []string{"tool"}},
+		{archive: "tool.gz", files: []string{"tool"}},
+		{archive: "traversal.tar", files: []string{"evil", "tool"}},
+		{archive: "traversal.zip", files: []string{"evil", "tool"}},
+		{archive: "absolute.tar", files: []string{"getghrel-evil", "tool"}},
+		{archive: "symlink_escape.tar", files: []string{"tool"}},
+		{archive: "symlink_chain.tar", files: []string{"evil"}},
+		{archive: "hardlink_escape.tar", wantErr: true},
+		{archive: "hardlink_missing.tar", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.archive, func(t *testing.T) {
+			root, src, dest := setupArchive(t, tt.archive)
+			files, err := Extractor(src, dest, DefaultExtractOptions)
+			checkExtracted(t, root, dest, err != nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Extractor() = %v, want an error", filesUnder(t, dest, files))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filesUnder(t, dest, files); !slices.Equal(got, tt.files) {
+				t.Errorf("Extractor() = %v, want %v", got, tt.files)
+			}
+		})
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		opts    ExtractOptions
+		files   []string
+		wantErr bool
+	}{
+		{name: "size", archive: "bomb.tar.gz", opts: ExtractOptions{MaxSize: 1 << 20}, files: []string{"zeros"}},
+		{name: "over the size", archive: "bomb.tar.gz", opts: ExtractOptions{MaxSize: 1<<20 - 1}, wantErr: true},
+		{name: "entries", archive: "tool.tar.gz", opts: ExtractOptions{MaxEntries: 5}, files: []string{"doc/README.md", "tool", "tool-1.0"}},
+		{name: "over the entries", archive: "tool.tar.gz", opts: ExtractOptions{MaxEntries: 4}, wantErr: true},
+		{name: "nested archive", archive: "nested.zip", opts: ExtractOptions{MaxDepth: 2}, files: []string{"tool"}},
+		{name: "nested archive over the depth", archive: "nested.zip", opts: ExtractOptions{MaxDepth: 1}, files: []string{"tool.tar.gz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, src, dest := setupArchive(t, tt.archive)
+			files, err := ExtractTree(src, dest, tt.opts)
+			checkExtracted(t, root, dest, err != nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ExtractTree() = %v, want an error", filesUnder(t, dest, files))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filesUnder(t, dest, files); !slices.Equal(got, tt.files) {
+				t.Errorf("ExtractTree() = %v, want %v", got, tt.files)
+			}
+		})
+	}
+}
+
+func TestExtractBinary(t *testing.T) {
+	// not an archive: kept as it is
+	root := t.TempDir()
+	src := filepath.Join(root, "tool-linux-amd64")
+	if err := os.WriteFile(src, []byte("\x7fELF"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if files, err := Extractor(src, root, DefaultExtractOptions); err != nil || len(files) != 0 {
+		t.Errorf("Extractor() = %v, %v, want nothing extracted", files, err)
+	}
+	dest := filepath.Join(root, "dest")
+	files, err := ExtractTree(src, dest, DefaultExtractOptions)
+	if err != nil || !slices.Equal(files, []string{filepath.Join(dest, "tool-linux-amd64")}) {
+		t.Errorf("ExtractTree() = %v, %v, want the binary moved inside %s", files, err, dest)
+	}
+}
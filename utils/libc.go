@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"debug/elf"
+	"fmt"
+	"strings"
+)
+
+// libc values accepted by -libc
+var libcs = []string{"gnu", "musl", "any"}
+
+/*
+- Return the libc of the system getghrel is running on: gnu or musl.
+
+- The dynamic loader of /bin/sh tells which one it is,
+for e.g "/lib/ld-musl-x86_64.so.1" on Alpine
+or "/lib64/ld-linux-x86-64.so.2" with glibc.
+An empty string is returned if it can't be found.
+*/
+func HostLibc() string {
+	f, err := elf.Open("/bin/sh")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		interp := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(interp, 0); err != nil {
+			return ""
+		}
+		switch loader := string(interp); {
+		case strings.Contains(loader, "musl"):
+			return "musl"
+		case strings.Contains(loader, "ld-linux"):
+			return "gnu"
+		}
+	}
+	return ""
+}
+
+/*
+- Return the libc assets of a linux target should be built for.
+
+- The -libc value wins if there's one.
+Otherwise, the libc of the host is used when the target is the host itself,
+and any libc is accepted for other targets.
+*/
+func TargetLibc(p Platform, libc string) (string, error) {
+	if libc != "" && libc != "auto" {
+		if !contains(libcs, libc) {
+			return "", fmt.Errorf("-libc %q is not supported, use: %s", libc, strings.Join(libcs, ", "))
+		}
+		return libc, nil
+	}
+
+	host := HostPlatform()
+	if p.OS == "linux" && host.OS == p.OS && host.Arch == p.Arch {
+		if hostLibc := HostLibc(); hostLibc != "" {
+			return hostLibc, nil
+		}
+	}
+	return "any", nil
+}
@@ -0,0 +1,57 @@
+package utils
+
+import "strings"
+
+// words that come with the platform in asset names,
+// like in x86_64-unknown-linux-gnu or x86_64-pc-windows-msvc
+var platformWords = []string{"unknown", "pc", "msvc", "universal", "universal2"}
+
+// Return true if part of a name is a version, for e.g "v1.2.0" or "1.2"
+func isVersion(part string) bool {
+	part = strings.TrimPrefix(strings.ToLower(part), "v")
+	return part != "" && part[0] >= '0' && part[0] <= '9'
+}
+
+// Return true if part of a name is an OS, an architecture, a libc or a word that comes with them
+func isPlatformPart(part string) bool {
+	for _, table := range []map[string][]string{osAliases, archAliases, libcAliases} {
+		if len(findAliases(part, table)) > 0 {
+			return true
+		}
+	}
+	return contains(platformWords, strings.ToLower(part))
+}
+
+/*
+- Return the command name of an executable, without its version and platform,
+for e.g "tool-v1.2-linux-amd64" -> "tool" or "tool_1.2_windows_x86_64.exe" -> "tool.exe".
+
+- The name is cut before the first part (separated by "-" or "_")
+that is a version, an OS, an architecture or a libc. The first part is always kept.
+The .AppImage suffix is dropped, .exe is kept since windows needs it.
+*/
+func CommandName(name string) string {
+	ext := ""
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".exe"):
+		ext = name[len(name)-len(".exe"):]
+		name = name[:len(name)-len(".exe")]
+	case strings.HasSuffix(lower, ".appimage"):
+		name = name[:len(name)-len(".appimage")]
+	}
+
+	for i := 1; i < len(name); i++ {
+		if name[i] != '-' && name[i] != '_' {
+			continue
+		}
+		part := name[i+1:]
+		if j := strings.IndexAny(part, "-_"); j != -1 {
+			part = part[:j]
+		}
+		if isVersion(part) || isPlatformPart(part) {
+			return name[:i] + ext
+		}
+	}
+	return name + ext
+}
@@ -1,11 +1,63 @@
 package utils
 
 import (
+	"bufio"
+	"os"
 	"runtime"
+	"strconv"
+	"strings"
 )
 
-func OsInfo() (string, string){
+func OsInfo() (string, string) {
 	os := runtime.GOOS
 	arch := runtime.GOARCH
 	return os, arch
 }
+
+/*
+- Return the arm version of the cpu (v5, v6 or v7) from /proc/cpuinfo,
+an empty string is returned if it can't be found.
+
+- The model name ends with the version, like "ARMv6-compatible processor rev 7 (v6l)".
+The "CPU architecture" line is only used without it,
+because some ARMv6 cpus (Raspberry Pi 1 and Zero) report "7" there.
+*/
+func armVersion() string {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var version string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch key {
+		case "model name", "Processor":
+			for _, v := range []string{"v5", "v6", "v7"} {
+				if strings.HasSuffix(value, "("+v+"l)") {
+					return v
+				}
+			}
+		case "CPU architecture":
+			// "7", or "8" for a 64-bit cpu running a 32-bit system
+			n, err := strconv.Atoi(value)
+			switch {
+			case err != nil:
+			case n >= 7:
+				version = "v7"
+			case n == 6:
+				version = "v6"
+			default:
+				version = "v5"
+			}
+		}
+	}
+	return version
+}
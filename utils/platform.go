@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// aliases used in asset names for each operating system (GOOS)
+var osAliases = map[string][]string{
+	"linux":     {"linux"},
+	"darwin":    {"darwin", "macos", "mac", "osx", "apple"},
+	"windows":   {"windows", "win", "win32", "win64"},
+	"freebsd":   {"freebsd"},
+	"netbsd":    {"netbsd"},
+	"openbsd":   {"openbsd"},
+	"dragonfly": {"dragonfly", "dragonflybsd"},
+	"android":   {"android"},
+	"illumos":   {"illumos"},
+	"solaris":   {"solaris", "sunos"},
+	"aix":       {"aix"},
+}
+
+// aliases used in asset names for each architecture (GOARCH),
+// with the amd64 microarchitecture levels (amd64v3, the amd64_v3 of goreleaser already matches amd64)
+var archAliases = map[string][]string{
+	"amd64":    {"amd64", "x86_64", "x86-64", "x64", "linux64", "win64", "amd64v2", "amd64v3", "amd64v4", "x86_64v2", "x86_64v3", "x86_64v4", "x64v2", "x64v3", "x64v4"},
+	"arm64":    {"arm64", "aarch64"},
+	"386":      {"386", "i386", "i586", "i686", "x86", "x86_32", "ia32"},
+	"arm":      {"arm", "armv5", "armv5l", "armv5te", "armv6", "armv6l", "armv6hf", "armv7", "armv7l", "armv7hf", "armv7a", "armhf", "armel"},
+	"riscv64":  {"riscv64", "riscv64gc"},
+	"ppc64le":  {"ppc64le", "ppc64el", "powerpc64le"},
+	"ppc64":    {"ppc64", "powerpc64"},
+	"s390x":    {"s390x"},
+	"loong64":  {"loong64", "loongarch64"},
+	"mips64le": {"mips64le", "mips64el"},
+	"mips64":   {"mips64"},
+	"mipsle":   {"mipsle", "mipsel"},
+	"mips":     {"mips"},
+}
+
+// aliases of each 32-bit arm version (GOARM)
+var armVariants = map[string][]string{
+	"v5": {"armv5", "armv5l", "armv5te", "armel"},
+	"v6": {"armv6", "armv6l", "armv6hf"},
+	"v7": {"armv7", "armv7l", "armv7hf", "armv7a", "armhf"},
+}
+
+/*
+- Every os/arch pair getghrel can list/download releases for.
+Adding a platform is a matter of adding it here
+(and its aliases to osAliases and archAliases if it's a new one).
+
+- These are the unix targets of 'go tool dist list',
+except aix whose binaries are XCOFF, a format the
+standard library can't read, and ios. Plus windows on amd64 and arm64.
+*/
+var platformTable = map[string][]string{
+	"android":   {"386", "amd64", "arm", "arm64"},
+	"darwin":    {"amd64", "arm64"},
+	"dragonfly": {"amd64"},
+	"freebsd":   {"386", "amd64", "arm", "arm64", "riscv64"},
+	"illumos":   {"amd64"},
+	"linux":     {"386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le", "mipsle", "ppc64", "ppc64le", "riscv64", "s390x"},
+	"netbsd":    {"386", "amd64", "arm", "arm64"},
+	"openbsd":   {"386", "amd64", "arm", "arm64", "ppc64", "riscv64"},
+	"solaris":   {"amd64"},
+	"windows":   {"amd64", "arm64"},
+}
+
+/*
+- Platform is the os/arch pair releases are listed and downloaded for.
+
+- Variant is the arm version (v5, v6 or v7) for the 32-bit arm architecture,
+empty when unknown or for any other architecture.
+
+- Libc is the libc linux assets should be built for: gnu, musl or any.
+It's not part of the "os/arch" string.
+*/
+type Platform struct {
+	OS      string
+	Arch    string
+	Variant string
+	Libc    string
+}
+
+func (p Platform) String() string {
+	if p.Variant != "" {
+		return p.OS + "/" + p.Arch + "/" + p.Variant
+	}
+	return p.OS + "/" + p.Arch
+}
+
+// Return the platform getghrel is running on
+func HostPlatform() Platform {
+	ost, arch := OsInfo()
+	p := Platform{OS: ost, Arch: arch}
+	if arch == "arm" {
+		p.Variant = armVersion()
+	}
+	return p
+}
+
+// Return the GOOS/GOARCH name for an alias,
+// for e.g "macos" -> "darwin" or "x86_64" -> "amd64"
+func canonical(name string, table map[string][]string) string {
+	name = strings.ToLower(name)
+	if _, ok := table[name]; ok {
+		return name
+	}
+	for key, aliases := range table {
+		if contains(aliases, name) {
+			return key
+		}
+	}
+	return name
+}
+
+/*
+- Parse a platform in the "os/arch" or "os/arch/variant" format,
+for e.g "linux/arm64" or "linux/arm/v7".
+
+- Aliases are accepted as well, "linux/armv7" is the same as "linux/arm/v7"
+and "macos/x86_64" the same as "darwin/amd64".
+*/
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, fmt.Errorf("%q is not in the os/arch format, for e.g linux/arm64", s)
+	}
+	p := Platform{
+		OS:   canonical(parts[0], osAliases),
+		Arch: canonical(parts[1], archAliases),
+	}
+
+	if p.Arch == "arm" {
+		if variants := findAliases(parts[1], armVariants); len(variants) == 1 {
+			p.Variant = variants[0]
+		}
+		if len(parts) == 3 {
+			p.Variant = strings.ToLower(parts[2])
+		}
+	}
+	if len(parts) == 3 && p.Variant == "" {
+		return Platform{}, fmt.Errorf("%q: only arm has a variant, for e.g linux/arm/v7", s)
+	}
+
+	return p, SupportedPlatform(p)
+}
+
+// Return an error if there is no support for the platform
+func SupportedPlatform(p Platform) error {
+	arches, ok := platformTable[p.OS]
+	if !ok {
+		oses := make([]string, 0, len(platformTable))
+		for ost := range platformTable {
+			oses = append(oses, ost)
+		}
+		sort.Strings(oses)
+		return fmt.Errorf("%s is not supported, supported OS: %s", p.OS, strings.Join(oses, ", "))
+	}
+	if !contains(arches, p.Arch) {
+		return fmt.Errorf("%s is not supported, supported architectures for %s: %s", p, p.OS, strings.Join(arches, ", "))
+	}
+	if _, ok := armVariants[p.Variant]; p.Variant != "" && !ok {
+		return fmt.Errorf("%s is not supported, arm variants: v5, v6, v7", p)
+	}
+	return nil
+}
+
+/*
+- Return the directory in which the assets of a platform are downloaded.
+
+- With a single platform, it's the tempdir itself.
+With multiple platforms, each one gets its own directory
+so binaries with the same name don't overwrite each other,
+for e.g /tmp/getghrel/linux-arm64
+*/
+func PlatformDir(tempdir string, p Platform, platforms []Platform) string {
+	if len(platforms) < 2 {
+		return tempdir
+	}
+	return filepath.Join(tempdir, strings.ReplaceAll(p.String(), "/", "-"))
+}
+
+type span struct {
+	start, end int
+	key        string
+}
+
+func isAlnum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+func isAlpha(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+/*
+- Find every key of the table whose aliases appear in name.
+
+- An alias has to start at a word boundary and must not be followed
+by a letter, so "mac" does not match "machine", but "linux64" matches "linux".
+
+- A match that sits inside a longer match of another key is ignored:
+"x86" inside "x86_64" or "arm" inside "arm64" belong to the longer alias.
+*/
+func findAliases(name string, table map[string][]string) []string {
+	name = strings.ToLower(name)
+	var spans []span
+
+	for key, aliases := range table {
+		for _, alias := range aliases {
+			for offset := 0; offset < len(name); {
+				i := strings.Index(name[offset:], alias)
+				if i == -1 {
+					break
+				}
+				start := offset + i
+				end := start + len(alias)
+				offset = start + 1
+
+				if start > 0 && isAlnum(name[start-1]) {
+					continue
+				}
+				if end < len(name) && isAlpha(name[end]) {
+					continue
+				}
+				spans = append(spans, span{start, end, key})
+			}
+		}
+	}
+
+	found := make(map[string]bool)
+	for _, s := range spans {
+		contained := false
+		for _, o := range spans {
+			if o.key != s.key && o.start <= s.start && s.end <= o.end && o.end-o.start > s.end-s.start {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			found[s.key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(found))
+	for k := range found {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
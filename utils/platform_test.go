@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Platform
+		wantErr bool
+	}{
+		{s: "linux/arm64", want: Platform{OS: "linux", Arch: "arm64"}},
+		{s: "macos/x86_64", want: Platform{OS: "darwin", Arch: "amd64"}},
+		{s: "Linux/AMD64", want: Platform{OS: "linux", Arch: "amd64"}},
+		{s: "linux/aarch64", want: Platform{OS: "linux", Arch: "arm64"}},
+		{s: "win/x64", want: Platform{OS: "windows", Arch: "amd64"}},
+		{s: "linux/arm/v7", want: Platform{OS: "linux", Arch: "arm", Variant: "v7"}},
+		{s: "linux/armv7", want: Platform{OS: "linux", Arch: "arm", Variant: "v7"}},
+		{s: "linux/armhf", want: Platform{OS: "linux", Arch: "arm", Variant: "v7"}},
+		{s: "linux/arm", want: Platform{OS: "linux", Arch: "arm"}},
+		{s: "linux/i686", want: Platform{OS: "linux", Arch: "386"}},
+		{s: "linux/powerpc64le", want: Platform{OS: "linux", Arch: "ppc64le"}},
+		{s: "linux", wantErr: true},
+		{s: "linux/", wantErr: true},
+		{s: "linux/amd64/v3", wantErr: true},
+		{s: "linux/arm/v8", wantErr: true},
+		{s: "plan9/amd64", wantErr: true},
+		{s: "darwin/386", wantErr: true},
+		{s: "windows/arm", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePlatform(tt.s)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePlatform(%q) = %s, want an error", tt.s, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePlatform(%q) = %+v, %v, want %+v", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestFindAliases(t *testing.T) {
+	tests := []struct {
+		name   string
+		oses   []string
+		arches []string
+	}{
+		{name: "tool-linux-amd64.tar.gz", oses: []string{"linux"}, arches: []string{"amd64"}},
+		{name: "tool-x86_64-unknown-linux-musl.tar.gz", oses: []string{"linux"}, arches: []string{"amd64"}},
+		{name: "tool-aarch64-apple-darwin.tar.gz", oses: []string{"darwin"}, arches: []string{"arm64"}},
+		{name: "tool_Darwin_arm64.tar.gz", oses: []string{"darwin"}, arches: []string{"arm64"}},
+		{name: "tool-macOS-universal.zip", oses: []string{"darwin"}},
+		// the longer alias wins: x86 in x86_64, arm in arm64, win in windows
+		{name: "tool-windows-x86_64.zip", oses: []string{"windows"}, arches: []string{"amd64"}},
+		{name: "tool-linux-x86.tar.gz", oses: []string{"linux"}, arches: []string{"386"}},
+		{name: "tool-linux-armv7.tar.gz", oses: []string{"linux"}, arches: []string{"arm"}},
+		{name: "tool-linux64.tar.gz", oses: []string{"linux"}, arches: []string{"amd64"}},
+		{name: "tool-win64.zip", oses: []string{"windows"}, arches: []string{"amd64"}},
+		{name: "tool-linux-x86_64v3.tar.gz", oses: []string{"linux"}, arches: []string{"amd64"}},
+		{name: "tool-linux-amd64v3.tar.gz", oses: []string{"linux"}, arches: []string{"amd64"}},
+		{name: "tool-linux-amd64_v3.tar.gz", oses: []string{"linux"}, arches: []string{"amd64"}},
+		{name: "tool-linux-mips64el.tar.gz", oses: []string{"linux"}, arches: []string{"mips64le"}},
+		{name: "tool-linux-loongarch64.tar.gz", oses: []string{"linux"}, arches: []string{"loong64"}},
+		// words that only start like an alias
+		{name: "machine-learning-tool.tar.gz"},
+		{name: "armada-winner.tar.gz"},
+		{name: "tool-1.0.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := findAliases(tt.name, osAliases); !slices.Equal(got, tt.oses) && len(got)+len(tt.oses) > 0 {
+			t.Errorf("findAliases(%q, osAliases) = %v, want %v", tt.name, got, tt.oses)
+		}
+		if got := findAliases(tt.name, archAliases); !slices.Equal(got, tt.arches) && len(got)+len(tt.arches) > 0 {
+			t.Errorf("findAliases(%q, archAliases) = %v, want %v", tt.name, got, tt.arches)
+		}
+	}
+}
+
+func TestSupportedPlatform(t *testing.T) {
+	// every platform of the table has the aliases to find its assets
+	for ost, arches := range platformTable {
+		if _, ok := osAliases[ost]; !ok {
+			t.Errorf("%s has no aliases", ost)
+		}
+		for _, arch := range arches {
+			if _, ok := archAliases[arch]; !ok {
+				t.Errorf("%s has no aliases", arch)
+			}
+			if err := SupportedPlatform(Platform{OS: ost, Arch: arch}); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestPlatformDir(t *testing.T) {
+	arm := Platform{OS: "linux", Arch: "arm", Variant: "v7"}
+	if got := PlatformDir("/tmp/getghrel", arm, []Platform{arm}); got != "/tmp/getghrel" {
+		t.Errorf("PlatformDir() = %s with a single platform, want the tempdir", got)
+	}
+	if got := PlatformDir("/tmp/getghrel", arm, []Platform{linuxAmd64, arm}); got != "/tmp/getghrel/linux-arm-v7" {
+		t.Errorf("PlatformDir() = %s, want /tmp/getghrel/linux-arm-v7", got)
+	}
+}
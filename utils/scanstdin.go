@@ -3,14 +3,24 @@ package utils
 import (
 	"bufio"
 	"os"
+	"strings"
 )
 
 // scan StdIn and send each line to the apiUrl channel
+// comments (anything after " #") and empty lines are skipped
 
 func ScanStdIn(apiUrl chan string) {
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
-		apiUrl <- scanner.Text()
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i != -1 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		apiUrl <- line
 	}
 	close(apiUrl)
 }
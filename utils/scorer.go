@@ -0,0 +1,382 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Asset is a file attached to a github release
+type Asset struct {
+	Name string
+	URL  string
+	Size int64
+	// the digest github computed for the asset, empty for older releases
+	SHA256 string
+	// the url of the asset in the github api, the one the assets of private repos download from
+	APIURL string
+}
+
+// Return the url the asset is downloaded from: its api url when it has one, which works
+// for private repos too, otherwise its browser download url
+func (a Asset) DownloadURL() string {
+	if a.APIURL != "" {
+		return a.APIURL
+	}
+	return a.URL
+}
+
+// Candidate is an asset with the score it got for a platform
+// and the reasons behind that score
+type Candidate struct {
+	Asset
+	Score     int
+	Reasons   []string
+	Rejected  bool
+	OSMatch   bool
+	ArchMatch bool
+	Universal bool
+	Script    bool
+}
+
+// archive and compression formats, the longest suffix is checked first
+var assetKinds = []struct {
+	suffix string
+	kind   string
+	score  int
+}{
+	{".tar.gz", "tar.gz", 10},
+	{".tgz", "tar.gz", 10},
+	{".tar.xz", "tar.xz", 10},
+	{".txz", "tar.xz", 10},
+	{".tar.zst", "tar.zst", 9},
+	{".tar.bz2", "tar.bz2", 9},
+	{".tbz2", "tar.bz2", 9},
+	{".tbz", "tar.bz2", 9},
+	{".tar", "tar", 8},
+	{".zip", "zip", 8},
+	{".7z", "7z", 5},
+	{".gz", "gz", 6},
+	{".xz", "xz", 6},
+	{".bz2", "bz2", 6},
+	{".zst", "zst", 6},
+	{".exe", "exe", 9},
+	{".appimage", "appimage", 6},
+	{".sh", "script", 3},
+}
+
+// windows has its own preferences: zip is its native archive format
+// and msvc builds don't need the mingw runtime
+var windowsKindScore = map[string]int{
+	"zip": 10,
+}
+
+var msvcWords = []string{"msvc"}
+
+// assets that are never a release binary
+var rejectedSuffixes = []string{
+	// packages for a package manager
+	".deb", ".rpm", ".apk", ".pkg", ".dmg", ".msi", ".snap", ".flatpak",
+	// checksums, signatures, sboms and other metadata
+	".sha256", ".sha256sum", ".sha512", ".sha512sum", ".md5", ".sig", ".asc",
+	".pem", ".crt", ".cert", ".sbom", ".spdx", ".json", ".jsonl", ".txt",
+	".yml", ".yaml", ".minisig", ".bundle",
+}
+
+var rejectedWords = []string{"checksums", "sha256sums", "sha512sums", "sbom"}
+
+// scripts that install a tool instead of being the tool
+var installerWords = []string{"install", "installer", "uninstall", "setup", "bootstrap"}
+
+// macOS builds that run on every architecture
+var universalWords = []string{"universal", "universal2", "all"}
+
+// words that lower the score of an asset
+var penalties = []struct {
+	words  []string
+	reason string
+	score  int
+}{
+	{[]string{"debug", "dbg", "debuginfo", "symbols", "dsym", "pdb"}, "debug symbols", -25},
+	{[]string{"source", "sources", "src"}, "source code", -40},
+}
+
+// libc variants found in linux asset names
+var libcAliases = map[string][]string{
+	"gnu":    {"gnu", "glibc", "gnueabi", "gnueabihf"},
+	"musl":   {"musl", "musleabi", "musleabihf"},
+	"static": {"static"},
+}
+
+// score of each libc variant of an asset for the libc of the target.
+// static and musl builds run everywhere, gnu builds need glibc:
+// a variant missing from the target's map is rejected,
+// and without a known libc the builds that run everywhere are preferred
+var libcScore = map[string]map[string]int{
+	"gnu":  {"gnu": 3, "musl": 2, "static": 2},
+	"musl": {"musl": 5, "static": 4},
+	"any":  {"gnu": 2, "musl": 3, "static": 3},
+}
+
+func hasWord(name string, words []string) bool {
+	table := map[string][]string{"": words}
+	return len(findAliases(name, table)) > 0
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+- Score a single asset for the platform.
+
+- An asset naming another OS or architecture (and not ours) is rejected,
+so are packages, checksums, signatures and other metadata.
+Shell scripts (.sh) match every unix, and -keep decides if cleanup
+keeps them. Install scripts are rejected.
+
+- Everything else gets points for matching the OS and the architecture,
+for the libc variant and for the archive type. Debug and source
+assets are penalized.
+*/
+func ScoreAsset(asset Asset, p Platform) Candidate {
+	c := Candidate{Asset: asset}
+	ost, arch := p.OS, p.Arch
+	name := strings.ToLower(asset.Name)
+
+	reject := func(reason string) Candidate {
+		c.Rejected = true
+		c.Score = 0
+		c.Reasons = append(c.Reasons, reason)
+		return c
+	}
+
+	for _, suffix := range rejectedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return reject("file type: " + suffix)
+		}
+	}
+	if hasWord(name, rejectedWords) {
+		return reject("metadata")
+	}
+
+	oses := findAliases(name, osAliases)
+	isExe := strings.HasSuffix(name, ".exe")
+	isAppImage := strings.HasSuffix(name, ".appimage")
+	isScript := strings.HasSuffix(name, ".sh")
+	switch {
+	case isExe && ost != "windows":
+		return reject("file type: .exe")
+	case isAppImage && ost != "linux":
+		return reject("file type: .appimage")
+	case isScript && ost == "windows":
+		return reject("file type: .sh")
+	case isScript && hasWord(name, installerWords):
+		return reject("installer script")
+	// tool.exe is a windows binary even without "windows" in its name
+	case isExe && len(oses) == 0:
+		c.OSMatch = true
+		c.Score += 40
+		c.Reasons = append(c.Reasons, "os:windows (.exe)")
+	// and tool.AppImage a linux one
+	case isAppImage && len(oses) == 0:
+		c.OSMatch = true
+		c.Score += 40
+		c.Reasons = append(c.Reasons, "os:linux (.appimage)")
+	// a shell script runs on any unix, after the assets naming the OS
+	case isScript && len(oses) == 0:
+		c.OSMatch = true
+		c.Script = true
+		c.Score += 20
+		c.Reasons = append(c.Reasons, "os:any (.sh)")
+	case contains(oses, ost):
+		c.OSMatch = true
+		c.Score += 40
+		c.Reasons = append(c.Reasons, "os:"+ost)
+	case len(oses) > 0:
+		return reject(fmt.Sprintf("os:%s (want %s)", strings.Join(oses, ","), ost))
+	}
+
+	arches := findAliases(name, archAliases)
+	switch {
+	case contains(arches, arch):
+		c.ArchMatch = true
+		c.Score += 30
+		c.Reasons = append(c.Reasons, "arch:"+arch)
+	case len(arches) > 0:
+		return reject(fmt.Sprintf("arch:%s (want %s)", strings.Join(arches, ","), arch))
+	case ost == "darwin" && hasWord(name, universalWords):
+		c.Universal = true
+		c.Score += 20
+		c.Reasons = append(c.Reasons, "arch:universal")
+	default:
+		c.Reasons = append(c.Reasons, "arch:none")
+	}
+
+	if c.ArchMatch && arch == "arm" {
+		if reason, score, ok := scoreArmVariant(name, p.Variant); !ok {
+			return reject(reason)
+		} else if reason != "" {
+			c.Score += score
+			c.Reasons = append(c.Reasons, reason)
+		}
+	}
+
+	if ost == "linux" {
+		want := p.Libc
+		if want == "" {
+			want = "any"
+		}
+		for _, libc := range findAliases(name, libcAliases) {
+			score, ok := libcScore[want][libc]
+			if !ok {
+				return reject(fmt.Sprintf("libc:%s (want %s)", libc, want))
+			}
+			c.Score += score
+			c.Reasons = append(c.Reasons, "libc:"+libc)
+		}
+	}
+
+	if ost == "windows" && hasWord(name, msvcWords) {
+		c.Score += 3
+		c.Reasons = append(c.Reasons, "toolchain:msvc")
+	}
+
+	kind, kindScore := assetKind(name)
+	if score, ok := windowsKindScore[kind]; ok && ost == "windows" {
+		kindScore = score
+	}
+	c.Score += kindScore
+	c.Reasons = append(c.Reasons, "archive:"+kind)
+
+	for _, p := range penalties {
+		if hasWord(name, p.words) {
+			c.Score += p.score
+			c.Reasons = append(c.Reasons, fmt.Sprintf("%s (%d)", p.reason, p.score))
+		}
+	}
+
+	return c
+}
+
+// Return the kind of an asset (tar.gz, zip, exe...) from its name and its score,
+// an asset without a known suffix is a binary
+func assetKind(name string) (string, int) {
+	name = strings.ToLower(name)
+	for _, k := range assetKinds {
+		if strings.HasSuffix(name, k.suffix) {
+			return k.kind, k.score
+		}
+	}
+	return "binary", 7
+}
+
+/*
+- Score the arm version (v5, v6, v7) of an asset for the wanted version.
+
+- The exact version is preferred, an older one still runs on a newer cpu,
+and a newer one is rejected. Assets without a version (just "arm")
+and targets without a version are neither preferred nor rejected.
+*/
+func scoreArmVariant(name, want string) (string, int, bool) {
+	variants := findAliases(name, armVariants)
+	switch {
+	case len(variants) == 0:
+		return "", 0, true
+	case want == "" || contains(variants, want):
+		return "arm:" + strings.Join(variants, ","), 5, true
+	}
+
+	for _, v := range variants {
+		// "v5" < "v6" < "v7"
+		if v < want {
+			return fmt.Sprintf("arm:%s (older than %s)", v, want), 2, true
+		}
+	}
+	return fmt.Sprintf("arm:%s (want %s)", strings.Join(variants, ","), want), 0, false
+}
+
+// Score every asset and sort them from best to worst,
+// rejected assets come last
+func RankAssets(assets []Asset, p Platform) []Candidate {
+	ranked := make([]Candidate, 0, len(assets))
+	for _, a := range assets {
+		ranked = append(ranked, ScoreAsset(a, p))
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Rejected != b.Rejected {
+			return !a.Rejected
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		// on a tie, the shorter name usually has fewer extras
+		return len(a.Name) < len(b.Name)
+	})
+	return ranked
+}
+
+// Return why the asset is a low confidence match,
+// or an empty string if it names both the OS and the architecture
+func (c Candidate) LowConfidence() string {
+	switch {
+	case c.OSMatch && c.ArchMatch:
+		return ""
+	case c.Universal:
+		return "universal build"
+	case c.Script:
+		return "script, no OS in the asset name"
+	default:
+		return "os only, no architecture in the asset name"
+	}
+}
+
+/*
+- Return the best asset for the platform.
+
+- Assets that match both the OS and the architecture come first.
+When there is none, an asset that names only the OS
+(or a universal macOS build) is returned as a low confidence match.
+*/
+func BestAsset(ranked []Candidate) (Candidate, bool) {
+	for _, c := range ranked {
+		if !c.Rejected && c.OSMatch && c.ArchMatch {
+			return c, true
+		}
+	}
+	for _, c := range ranked {
+		if !c.Rejected && c.OSMatch {
+			return c, true
+		}
+	}
+	return Candidate{}, false
+}
+
+/*
+- Return the platform an asset was built for, among the given platforms.
+
+- It's used by -download to know in which directory an asset goes
+and which binary format to expect when there are multiple targets.
+The platform the asset scores the highest for wins,
+the first platform is returned when none of them matches.
+*/
+func MatchPlatform(name string, platforms []Platform) Platform {
+	best, bestScore := platforms[0], -1
+	for _, p := range platforms {
+		c := ScoreAsset(Asset{Name: name}, p)
+		if c.Rejected || !c.OSMatch {
+			continue
+		}
+		if c.Score > bestScore {
+			best, bestScore = p, c.Score
+		}
+	}
+	return best
+}
@@ -0,0 +1,283 @@
+package utils
+
+import (
+	"testing"
+)
+
+func assets(names ...string) []Asset {
+	var list []Asset
+	for _, n := range names {
+		list = append(list, Asset{Name: n})
+	}
+	return list
+}
+
+var (
+	linuxAmd64  = Platform{OS: "linux", Arch: "amd64"}
+	linuxArm64  = Platform{OS: "linux", Arch: "arm64"}
+	darwinArm64 = Platform{OS: "darwin", Arch: "arm64"}
+)
+
+func TestBestAsset(t *testing.T) {
+	tests := []struct {
+		name     string
+		assets   []string
+		platform Platform
+		best     string // empty when there's none
+		low      bool   // a low confidence match
+	}{
+		{
+			name:     "os and arch over the rest",
+			assets:   []string{"tool_1.0_checksums.txt", "tool-1.0-darwin-amd64.tar.gz", "tool-1.0-linux-arm64.tar.gz", "tool-1.0-linux-amd64.tar.gz", "tool-1.0-linux-amd64.deb"},
+			platform: linuxAmd64,
+			best:     "tool-1.0-linux-amd64.tar.gz",
+		},
+		{
+			name:     "tar.gz over zip",
+			assets:   []string{"tool-linux-x86_64.zip", "tool-linux-x86_64.tar.gz"},
+			platform: linuxAmd64,
+			best:     "tool-linux-x86_64.tar.gz",
+		},
+		{
+			name:     "zip on windows",
+			assets:   []string{"tool-windows-amd64.tar.gz", "tool-windows-amd64.zip"},
+			platform: Platform{OS: "windows", Arch: "amd64"},
+			best:     "tool-windows-amd64.zip",
+		},
+		{
+			name:     "msvc over gnu on windows",
+			assets:   []string{"tool-x86_64-pc-windows-gnu.zip", "tool-x86_64-pc-windows-msvc.zip"},
+			platform: Platform{OS: "windows", Arch: "amd64"},
+			best:     "tool-x86_64-pc-windows-msvc.zip",
+		},
+		{
+			name:     "a .exe is a windows binary",
+			assets:   []string{"tool-amd64.exe", "tool-arm64.exe"},
+			platform: Platform{OS: "windows", Arch: "amd64"},
+			best:     "tool-amd64.exe",
+		},
+		{
+			name:     "debug symbols and sources are penalized",
+			assets:   []string{"tool-linux-amd64-debug.tar.gz", "tool-src-linux-amd64.tar.gz", "tool-linux-amd64.tar.xz"},
+			platform: linuxAmd64,
+			best:     "tool-linux-amd64.tar.xz",
+		},
+		{
+			name:     "aliases of the os and arch",
+			assets:   []string{"tool-macos-x86_64.zip", "tool-macos-aarch64.zip"},
+			platform: darwinArm64,
+			best:     "tool-macos-aarch64.zip",
+		},
+		{
+			name:     "on a tie the shorter name",
+			assets:   []string{"tool-linux-amd64-extra.tar.gz", "tool-linux-amd64.tar.gz"},
+			platform: linuxAmd64,
+			best:     "tool-linux-amd64.tar.gz",
+		},
+		{
+			name:     "the closest older arm version",
+			assets:   []string{"tool-linux-armv7.tar.gz", "tool-linux-armv6.tar.gz"},
+			platform: Platform{OS: "linux", Arch: "arm", Variant: "v6"},
+			best:     "tool-linux-armv6.tar.gz",
+		},
+		{
+			name:     "no newer arm version",
+			assets:   []string{"tool-linux-armv7.tar.gz"},
+			platform: Platform{OS: "linux", Arch: "arm", Variant: "v6"},
+		},
+		{
+			name:     "os only",
+			assets:   []string{"nvim-linux.tar.gz", "nvim-macos.tar.gz", "nvim.appimage.sha256sum"},
+			platform: linuxAmd64,
+			best:     "nvim-linux.tar.gz",
+			low:      true,
+		},
+		{
+			name:     "os and arch over os only",
+			assets:   []string{"tool-linux.tar.gz", "tool-linux-arm64.tar.gz"},
+			platform: linuxArm64,
+			best:     "tool-linux-arm64.tar.gz",
+		},
+		{
+			name:     "no os only asset for another arch",
+			assets:   []string{"tool-linux.tar.gz", "tool-linux-arm64.tar.gz"},
+			platform: linuxAmd64,
+			best:     "tool-linux.tar.gz",
+			low:      true,
+		},
+		{
+			name:     "universal macOS build",
+			assets:   []string{"tool-darwin-universal.tar.gz", "tool-linux-amd64.tar.gz"},
+			platform: darwinArm64,
+			best:     "tool-darwin-universal.tar.gz",
+			low:      true,
+		},
+		{
+			name:     "universal is for macOS only",
+			assets:   []string{"tool-linux-universal.tar.gz"},
+			platform: linuxAmd64,
+			best:     "tool-linux-universal.tar.gz",
+			low:      true,
+		},
+		{
+			name:     "an AppImage is a linux binary",
+			assets:   []string{"tool-x86_64.AppImage", "tool-x86_64.AppImage.zsync"},
+			platform: linuxAmd64,
+			best:     "tool-x86_64.AppImage",
+		},
+		{
+			name:     "no AppImage outside linux",
+			assets:   []string{"tool-x86_64.AppImage"},
+			platform: Platform{OS: "freebsd", Arch: "amd64"},
+		},
+		{
+			name:     "a release that ships only a script",
+			assets:   []string{"tool.sh", "tool.sh.sha256"},
+			platform: linuxAmd64,
+			best:     "tool.sh",
+			low:      true,
+		},
+		{
+			name:     "a script on macOS",
+			assets:   []string{"tool.sh"},
+			platform: darwinArm64,
+			best:     "tool.sh",
+			low:      true,
+		},
+		{
+			name:     "no script on windows",
+			assets:   []string{"tool.sh"},
+			platform: Platform{OS: "windows", Arch: "amd64"},
+		},
+		{
+			name:     "binaries over the script",
+			assets:   []string{"tool.sh", "tool-linux.tar.gz"},
+			platform: linuxAmd64,
+			best:     "tool-linux.tar.gz",
+			low:      true,
+		},
+		{
+			name:     "binaries of the os and arch over the script",
+			assets:   []string{"tool.sh", "tool-linux-amd64.tar.gz"},
+			platform: linuxAmd64,
+			best:     "tool-linux-amd64.tar.gz",
+		},
+		{
+			name:     "not an install script",
+			assets:   []string{"install.sh", "tool-installer.sh", "tool-windows-amd64.zip"},
+			platform: linuxAmd64,
+		},
+		{
+			name:     "another os only",
+			assets:   []string{"tool-windows-amd64.zip", "tool-darwin-amd64.tar.gz"},
+			platform: linuxAmd64,
+		},
+		{
+			name:     "metadata only",
+			assets:   []string{"checksums.txt", "tool.sbom.json", "tool-linux-amd64.tar.gz.sig", "tool-linux-amd64.tar.gz.pem"},
+			platform: linuxAmd64,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best, found := BestAsset(RankAssets(assets(tt.assets...), tt.platform))
+			if !found {
+				if tt.best != "" {
+					t.Fatalf("BestAsset() found nothing, want %s", tt.best)
+				}
+				return
+			}
+			if best.Name != tt.best {
+				t.Fatalf("BestAsset() = %s (%v), want %q", best.Name, best.Reasons, tt.best)
+			}
+			if low := best.LowConfidence() != ""; low != tt.low {
+				t.Errorf("BestAsset() = %s, low confidence %v, want %v", best.Name, low, tt.low)
+			}
+		})
+	}
+}
+
+func TestScoreAssetLibc(t *testing.T) {
+	const gnu, musl, static = "tool-x86_64-unknown-linux-gnu.tar.gz", "tool-x86_64-unknown-linux-musl.tar.gz", "tool-linux-amd64-static.tar.gz"
+	tests := []struct {
+		libc     string
+		assets   []string
+		best     string
+		rejected []string
+	}{
+		{libc: "gnu", assets: []string{musl, gnu}, best: gnu},
+		{libc: "gnu", assets: []string{static, gnu}, best: gnu},
+		{libc: "musl", assets: []string{gnu, musl}, best: musl, rejected: []string{gnu}},
+		{libc: "musl", assets: []string{gnu, static}, best: static, rejected: []string{gnu}},
+		{libc: "musl", assets: []string{gnu}, rejected: []string{gnu}},
+		// without a known libc, the builds that run everywhere
+		{libc: "any", assets: []string{gnu, musl}, best: musl},
+		{libc: "", assets: []string{gnu, musl}, best: musl},
+		{libc: "any", assets: []string{gnu}, best: gnu},
+	}
+	for _, tt := range tests {
+		p := linuxAmd64
+		p.Libc = tt.libc
+		ranked := RankAssets(assets(tt.assets...), p)
+		best, found := BestAsset(ranked)
+		if found != (tt.best != "") || best.Name != tt.best {
+			t.Errorf("libc %q: BestAsset(%v) = %q, want %q", tt.libc, tt.assets, best.Name, tt.best)
+		}
+		for _, c := range ranked {
+			if c.Rejected != contains(tt.rejected, c.Name) {
+				t.Errorf("libc %q: %s rejected %v (%v)", tt.libc, c.Name, c.Rejected, c.Reasons)
+			}
+		}
+	}
+
+	// the libc is for linux only
+	c := ScoreAsset(Asset{Name: "tool-x86_64-unknown-freebsd-gnu.tar.gz"}, Platform{OS: "freebsd", Arch: "amd64", Libc: "musl"})
+	if c.Rejected {
+		t.Errorf("ScoreAsset() rejected a freebsd asset for its libc: %v", c.Reasons)
+	}
+}
+
+func TestAssetKind(t *testing.T) {
+	tests := []struct {
+		name, kind string
+	}{
+		{"tool.tar.gz", "tar.gz"},
+		{"tool.TGZ", "tar.gz"},
+		{"tool.tar.xz", "tar.xz"},
+		{"tool.tar.zst", "tar.zst"},
+		{"tool.tbz2", "tar.bz2"},
+		{"tool.tbz", "tar.bz2"},
+		{"tool.zip", "zip"},
+		{"tool.gz", "gz"},
+		{"tool.exe", "exe"},
+		{"tool.AppImage", "appimage"},
+		{"tool.sh", "script"},
+		{"tool-linux-amd64", "binary"},
+		{"tool-1.2.3", "binary"},
+	}
+	for _, tt := range tests {
+		if kind, _ := assetKind(tt.name); kind != tt.kind {
+			t.Errorf("assetKind(%q) = %q, want %q", tt.name, kind, tt.kind)
+		}
+	}
+}
+
+func TestMatchPlatform(t *testing.T) {
+	platforms := []Platform{linuxAmd64, linuxArm64, darwinArm64}
+	tests := []struct {
+		name string
+		want Platform
+	}{
+		{"tool-linux-amd64.tar.gz", linuxAmd64},
+		{"tool-linux-aarch64.tar.gz", linuxArm64},
+		{"tool-macos-arm64.zip", darwinArm64},
+		{"tool-darwin-universal.tar.gz", darwinArm64},
+		// none of them, the first one
+		{"tool-windows-amd64.zip", linuxAmd64},
+	}
+	for _, tt := range tests {
+		if got := MatchPlatform(tt.name, platforms); got != tt.want {
+			t.Errorf("MatchPlatform(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
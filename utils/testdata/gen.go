@@ -0,0 +1,131 @@
+//go:build ignore
+
+// Generate the archives of the extractor tests: go run gen.go (from utils/testdata).
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"log"
+	"os"
+	"strings"
+)
+
+// entry of an archive: a file, a directory (name ending with /), a symlink or a hard link
+type entry struct {
+	name     string
+	content  string
+	symlink  string
+	hardlink string
+}
+
+func check(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func tarball(entries ...entry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0755, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		switch {
+		case strings.HasSuffix(e.name, "/"):
+			hdr.Typeflag = tar.TypeDir
+		case e.symlink != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Mode = tar.TypeSymlink, e.symlink, 0777
+		case e.hardlink != "":
+			hdr.Typeflag, hdr.Linkname = tar.TypeLink, e.hardlink
+		}
+		check(tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(e.content))
+		check(err)
+	}
+	check(tw.Close())
+	return buf.Bytes()
+}
+
+func gz(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	check(err)
+	check(zw.Close())
+	return buf.Bytes()
+}
+
+// a zip of files, or of a single file with its content already compressed
+func zipball(entries ...entry) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.SetMode(0755)
+		if e.symlink != "" {
+			hdr.SetMode(os.ModeSymlink | 0777)
+			e.content = e.symlink
+		}
+		w, err := zw.CreateHeader(hdr)
+		check(err)
+		_, err = w.Write([]byte(e.content))
+		check(err)
+	}
+	check(zw.Close())
+	return buf.Bytes()
+}
+
+func write(name string, data []byte) {
+	check(os.WriteFile(name, data, 0644))
+}
+
+func main() {
+	// a release: a top-level directory, a binary, its doc, a symlink and a hard link to it
+	write("tool.tar.gz", gz(tarball(
+		entry{name: "tool-1.0/"},
+		entry{name: "tool-1.0/tool", content: "#!/bin/sh\necho tool\n"},
+		entry{name: "tool-1.0/doc/README.md", content: "# tool\n"},
+		entry{name: "tool-1.0/doc/tool.md", symlink: "README.md"},
+		entry{name: "tool-1.0/tool-1.0", hardlink: "tool-1.0/tool"},
+	)))
+	write("tool.zip", zipball(
+		entry{name: "tool", content: "#!/bin/sh\necho tool\n"},
+		entry{name: "doc/README.md", content: "# tool\n"},
+	))
+	// a tar.gz inside a zip
+	write("nested.zip", zipball(entry{name: "tool.tar.gz", content: string(gz(tarball(entry{name: "tool", content: "tool\n"})))}))
+	write("tool.gz", gz([]byte("#!/bin/sh\necho tool\n")))
+
+	// zip slip: entries escaping the extraction directory, after a good one
+	write("traversal.tar", tarball(entry{name: "tool", content: "tool\n"}, entry{name: "../evil", content: "evil\n"}))
+	write("traversal.zip", zipball(entry{name: "tool", content: "tool\n"}, entry{name: "doc/../../evil", content: "evil\n"}))
+	write("absolute.tar", tarball(entry{name: "tool", content: "tool\n"}, entry{name: "/tmp/getghrel-evil", content: "evil\n"}))
+
+	// symlinks out of the extraction directory, and a file written through one
+	write("symlink_escape.tar", tarball(entry{name: "tool", content: "tool\n"}, entry{name: "up", symlink: "../"}))
+	write("symlink_absolute.tar", tarball(entry{name: "tool", content: "tool\n"}, entry{name: "etc", symlink: "/etc"}))
+	write("symlink_escape.zip", zipball(entry{name: "tool", content: "tool\n"}, entry{name: "up", symlink: "../.."}))
+	// every target is inside, but a/b is the extraction directory itself
+	// so "link" resolves to its parent, and "link/evil" would be written there
+	write("symlink_chain.tar", tarball(
+		entry{name: "a/"},
+		entry{name: "a/b", symlink: ".."},
+		entry{name: "link", symlink: "a/b/.."},
+		entry{name: "link/evil", content: "evil\n"},
+	))
+	// the same link, nothing written through it
+	write("symlink_resolve.tar", tarball(
+		entry{name: "a/"},
+		entry{name: "a/b", symlink: ".."},
+		entry{name: "link", symlink: "a/b/.."},
+	))
+
+	// hard links to a file outside the archive
+	write("hardlink_escape.tar", tarball(entry{name: "tool", content: "tool\n"}, entry{name: "passwd", hardlink: "../../etc/passwd"}))
+	write("hardlink_missing.tar", tarball(entry{name: "tool", content: "tool\n"}, entry{name: "copy", hardlink: "secret"}))
+
+	// 1 MiB of zeros, a few KiB compressed
+	write("bomb.tar.gz", gz(tarball(entry{name: "zeros", content: string(make([]byte, 1<<20))})))
+}
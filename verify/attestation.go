@@ -0,0 +1,284 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the Fulcio certificate extensions of the workflow that signed an attestation
+var (
+	oidBuildSignerURI   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 9}
+	oidSourceRepoURI    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 12}
+	oidSourceRepoDigest = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 13}
+)
+
+// the OIDC issuer of the github workflows
+const githubIssuer = "https://token.actions.githubusercontent.com"
+
+// the payload type of the DSSE envelope of an in-toto statement
+const inTotoPayloadType = "application/vnd.in-toto+json"
+
+// sigstoreBundle is a sigstore bundle of a DSSE envelope (v0.1 to v0.3), as the github attestations api serves it
+type sigstoreBundle struct {
+	VerificationMaterial struct {
+		// v0.3 has the certificate alone, the older versions a chain starting with it
+		Certificate *struct {
+			RawBytes string `json:"rawBytes"`
+		} `json:"certificate"`
+		X509CertificateChain *struct {
+			Certificates []struct {
+				RawBytes string `json:"rawBytes"`
+			} `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		TlogEntries []tlogEntry `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	DSSEEnvelope *struct {
+		Payload     string `json:"payload"`
+		PayloadType string `json:"payloadType"`
+		Signatures  []struct {
+			Sig string `json:"sig"`
+		} `json:"signatures"`
+	} `json:"dsseEnvelope"`
+}
+
+// tlogEntry is the transparency log entry of a sigstore bundle (its integers are strings)
+type tlogEntry struct {
+	LogIndex string `json:"logIndex"`
+	LogID    struct {
+		KeyID string `json:"keyId"`
+	} `json:"logId"`
+	KindVersion struct {
+		Kind string `json:"kind"`
+	} `json:"kindVersion"`
+	IntegratedTime   string `json:"integratedTime"`
+	InclusionPromise *struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	CanonicalizedBody string `json:"canonicalizedBody"`
+}
+
+// dsseBody is the part of a dsse entry of the transparency log that binds it to the envelope
+type dsseBody struct {
+	Spec struct {
+		PayloadHash struct {
+			Algorithm string `json:"algorithm"`
+			Value     string `json:"value"`
+		} `json:"payloadHash"`
+		Signatures []struct {
+			Signature string `json:"signature"`
+			Verifier  string `json:"verifier"` // base64 of the PEM certificate
+		} `json:"signatures"`
+	} `json:"spec"`
+}
+
+// statement is the in-toto statement of a SLSA provenance
+type statement struct {
+	Subject []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	PredicateType string `json:"predicateType"`
+	Predicate     struct {
+		BuildDefinition struct {
+			ExternalParameters struct {
+				Workflow struct {
+					Repository string `json:"repository"`
+					Path       string `json:"path"`
+					Ref        string `json:"ref"`
+				} `json:"workflow"`
+			} `json:"externalParameters"`
+		} `json:"buildDefinition"`
+	} `json:"predicate"`
+}
+
+// Provenance is where an attested asset was built
+type Provenance struct {
+	Source   string // the url of the repo it was built from
+	Commit   string // the commit it was built from
+	Workflow string // the workflow that built it, for e.g https://github.com/o/r/.github/workflows/release.yml@refs/tags/v1
+}
+
+/*
+- Verify a github artifact attestation (a sigstore bundle) of the asset
+with the sha256 digest, downloaded from repo (owner/repo).
+
+- The DSSE envelope must be signed by a Fulcio certificate issued to a github workflow,
+chaining up to the pinned Fulcio roots at the time the transparency log recorded it
+(the entry signed by a pinned Rekor key). The envelope must be the SLSA provenance
+of the asset, built from repo itself (not a fork, or another repo) by one of its
+own workflows, or by the workflow of the repo in the trusted-keys file.
+*/
+func (k *Keys) VerifyAttestation(repo, digest string, raw []byte) (Provenance, error) {
+	if len(k.Sigstore.FulcioRoots) == 0 {
+		return Provenance{}, fmt.Errorf("%w: no fulcio_roots to verify the attestation", ErrNoKey)
+	}
+
+	var b sigstoreBundle
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return Provenance{}, fmt.Errorf("invalid attestation: %w", err)
+	}
+	env := b.DSSEEnvelope
+	if env == nil || len(env.Signatures) == 0 {
+		return Provenance{}, errors.New("invalid attestation: no signed DSSE envelope")
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return Provenance{}, fmt.Errorf("invalid attestation: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(env.Signatures[0].Sig)
+	if err != nil {
+		return Provenance{}, fmt.Errorf("invalid attestation: %w", err)
+	}
+	cert, err := b.certificate()
+	if err != nil {
+		return Provenance{}, fmt.Errorf("invalid attestation: %w", err)
+	}
+
+	// the envelope was signed while the certificate was valid
+	integrated, err := k.verifyDSSEEntry(b.VerificationMaterial.TlogEntries, payload, sig, cert)
+	if err != nil {
+		return Provenance{}, err
+	}
+	if err := k.verifyCert(cert, integrated); err != nil {
+		return Provenance{}, err
+	}
+	if issuer := certIssuer(cert); issuer != githubIssuer {
+		return Provenance{}, fmt.Errorf("attestation signed by %q, not a github workflow", issuer)
+	}
+	if err := verifyBlob(cert.PublicKey, pae(env.PayloadType, payload), sig); err != nil {
+		return Provenance{}, fmt.Errorf("invalid attestation signature: %w", err)
+	}
+
+	if env.PayloadType != inTotoPayloadType {
+		return Provenance{}, fmt.Errorf("attestation of type %s, not an in-toto statement", env.PayloadType)
+	}
+	var s statement
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return Provenance{}, fmt.Errorf("invalid attestation: %w", err)
+	}
+	if !strings.HasPrefix(s.PredicateType, "https://slsa.dev/provenance/") {
+		return Provenance{}, fmt.Errorf("attestation of %s, not a SLSA provenance", s.PredicateType)
+	}
+	subject := false
+	for _, sub := range s.Subject {
+		if strings.EqualFold(sub.Digest["sha256"], digest) {
+			subject = true
+		}
+	}
+	if !subject {
+		return Provenance{}, errors.New("the attestation is for another file")
+	}
+
+	p := Provenance{
+		Source:   certExtension(cert, oidSourceRepoURI),
+		Commit:   certExtension(cert, oidSourceRepoDigest),
+		Workflow: certExtension(cert, oidBuildSignerURI),
+	}
+	if err := k.checkBuilder(repo, p, s); err != nil {
+		return Provenance{}, err
+	}
+	return p, nil
+}
+
+// Check an asset of repo was built from repo itself, by a workflow it trusts
+func (k *Keys) checkBuilder(repo string, p Provenance, s statement) error {
+	want := "https://github.com/" + repo
+	if !strings.EqualFold(p.Source, want) {
+		return fmt.Errorf("built from %s, not %s", p.Source, want)
+	}
+	if r := s.Predicate.BuildDefinition.ExternalParameters.Workflow.Repository; r != "" && !strings.EqualFold(r, want) {
+		return fmt.Errorf("the provenance says built from %s, not %s", r, want)
+	}
+
+	// a workflow of the repo itself, unless the trusted-keys file names one
+	if pattern := k.Repos[repo].Workflow; pattern != "" {
+		if !globMatch(pattern, p.Workflow) {
+			return fmt.Errorf("built by %s, not %s", p.Workflow, pattern)
+		}
+		return nil
+	}
+	if !strings.HasPrefix(strings.ToLower(p.Workflow), strings.ToLower(want+"/.github/workflows/")) {
+		return fmt.Errorf("built by %s, not a workflow of %s", p.Workflow, repo)
+	}
+	return nil
+}
+
+// Return the certificate of a sigstore bundle
+func (b *sigstoreBundle) certificate() (*x509.Certificate, error) {
+	var raw string
+	switch vm := b.VerificationMaterial; {
+	case vm.Certificate != nil:
+		raw = vm.Certificate.RawBytes
+	case vm.X509CertificateChain != nil && len(vm.X509CertificateChain.Certificates) > 0:
+		raw = vm.X509CertificateChain.Certificates[0].RawBytes
+	default:
+		return nil, errors.New("no certificate")
+	}
+	der, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+/*
+- Verify the signed entry timestamp of the dsse entry of a bundle with the pinned Rekor keys,
+and check the entry records the payload, the signature and the certificate of the envelope.
+
+- Return the time the entry was recorded.
+*/
+func (k *Keys) verifyDSSEEntry(entries []tlogEntry, payload, sig []byte, cert *x509.Certificate) (time.Time, error) {
+	for _, e := range entries {
+		if e.InclusionPromise == nil || e.KindVersion.Kind != "dsse" {
+			continue
+		}
+		integrated, err1 := strconv.ParseInt(e.IntegratedTime, 10, 64)
+		index, err2 := strconv.ParseInt(e.LogIndex, 10, 64)
+		logID, err3 := base64.StdEncoding.DecodeString(e.LogID.KeyID)
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return time.Time{}, fmt.Errorf("invalid transparency log entry: %w", err)
+		}
+		set := decodeBase64([]byte(e.InclusionPromise.SignedEntryTimestamp))
+		entry := rekorPayload{Body: e.CanonicalizedBody, IntegratedTime: integrated, LogID: hex.EncodeToString(logID), LogIndex: index}
+		if err := k.verifySET(entry, set); err != nil {
+			return time.Time{}, err
+		}
+
+		var body dsseBody
+		rawBody, err := base64.StdEncoding.DecodeString(e.CanonicalizedBody)
+		if err == nil {
+			err = json.Unmarshal(rawBody, &body)
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid transparency log entry: %w", err)
+		}
+		sum := sha256.Sum256(payload)
+		if body.Spec.PayloadHash.Algorithm != "sha256" || body.Spec.PayloadHash.Value != hex.EncodeToString(sum[:]) {
+			return time.Time{}, errors.New("the transparency log entry is for another attestation")
+		}
+		for _, s := range body.Spec.Signatures {
+			verifier, err := parseCerts(decodeBase64([]byte(s.Verifier)))
+			if err == nil && bytes.Equal(decodeBase64([]byte(s.Signature)), sig) && verifier[0].Equal(cert) {
+				return time.Unix(integrated, 0), nil
+			}
+		}
+		return time.Time{}, errors.New("the transparency log entry is for another signature")
+	}
+	// github signs the attestations of private repos with its own timestamp authority instead
+	return time.Time{}, errors.New("no transparency log entry in the attestation, signed timestamps are not supported")
+}
+
+// Return the pre-authentication encoding of a DSSE payload, what its signature signs
+func pae(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
@@ -0,0 +1,137 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// the sha256 of testdata/asset.txt, the subject of testdata/attestation.json
+func assetDigest(t *testing.T) string {
+	t.Helper()
+	sum := sha256.Sum256(readTestdata(t, "asset.txt"))
+	return hex.EncodeToString(sum[:])
+}
+
+// the sigstore roots testdata/attestation.json verifies with, made by gen.go -attestation
+const attestationRoots = "sigstore:\n  fulcio_roots: [testdata/attestation_fulcio.pem]\n  rekor_keys: [testdata/attestation_rekor.pub]\n"
+
+// Return testdata/attestation.json changed by edit
+func editAttestation(t *testing.T, edit func(b *sigstoreBundle)) []byte {
+	t.Helper()
+	var b sigstoreBundle
+	if err := json.Unmarshal(readTestdata(t, "attestation.json"), &b); err != nil {
+		t.Fatal(err)
+	}
+	edit(&b)
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifyAttestation(t *testing.T) {
+	k := loadKeys(t, attestationRoots+"repos: {}\n")
+	digest := assetDigest(t)
+	// the statement with another predicate type, its signature is of the original one
+	forged := func(b *sigstoreBundle) {
+		payload, _ := base64.StdEncoding.DecodeString(b.DSSEEnvelope.Payload)
+		var s map[string]any
+		json.Unmarshal(payload, &s)
+		s["predicateType"] = "https://slsa.dev/provenance/v0.2"
+		payload, _ = json.Marshal(s)
+		b.DSSEEnvelope.Payload = base64.StdEncoding.EncodeToString(payload)
+	}
+
+	tests := []struct {
+		name   string
+		repo   string
+		digest string
+		edit   func(b *sigstoreBundle)
+		want   string
+	}{
+		{name: "attested", repo: "owner/keyless"},
+		{name: "upper case digest", repo: "owner/keyless", digest: "upper"},
+		{name: "another file", repo: "owner/keyless", digest: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", want: "invalid"},
+		{name: "a fork or another repo", repo: "owner/other", want: "invalid"},
+		{name: "Owner/Keyless", repo: "Owner/Keyless"},
+		{name: "another payload", repo: "owner/keyless", edit: forged, want: "invalid"},
+		{name: "another signature", repo: "owner/keyless", edit: func(b *sigstoreBundle) {
+			b.DSSEEnvelope.Signatures[0].Sig = base64.StdEncoding.EncodeToString(decodeBase64(readTestdata(t, "keyless.sig")))
+		}, want: "invalid"},
+		{name: "another log index", repo: "owner/keyless", edit: func(b *sigstoreBundle) {
+			b.VerificationMaterial.TlogEntries[0].LogIndex = "44"
+		}, want: "invalid"},
+		{name: "another integrated time", repo: "owner/keyless", edit: func(b *sigstoreBundle) {
+			b.VerificationMaterial.TlogEntries[0].IntegratedTime = "1704103200"
+		}, want: "invalid"},
+		{name: "no transparency log entry", repo: "owner/keyless", edit: func(b *sigstoreBundle) {
+			b.VerificationMaterial.TlogEntries = nil
+		}, want: "invalid"},
+		{name: "no certificate", repo: "owner/keyless", edit: func(b *sigstoreBundle) {
+			b.VerificationMaterial.Certificate = nil
+		}, want: "invalid"},
+		{name: "no envelope", repo: "owner/keyless", edit: func(b *sigstoreBundle) { b.DSSEEnvelope = nil }, want: "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := readTestdata(t, "attestation.json")
+			if tt.edit != nil {
+				raw = editAttestation(t, tt.edit)
+			}
+			d := digest
+			switch tt.digest {
+			case "":
+			case "upper":
+				d = strings.ToUpper(digest)
+			default:
+				d = tt.digest
+			}
+			p, err := k.VerifyAttestation(tt.repo, d, raw)
+			if got := outcome(err); got != tt.want {
+				t.Fatalf("VerifyAttestation() = %+v, %v, want %q", p, err, tt.want)
+			}
+			if err == nil && (p.Workflow != workflow || p.Source != "https://github.com/owner/keyless") {
+				t.Errorf("VerifyAttestation() = %+v, want built by %s", p, workflow)
+			}
+		})
+	}
+}
+
+func TestVerifyAttestationKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{name: "the workflow of the trusted-keys file", keys: attestationRoots + "repos:\n  owner/keyless:\n    workflow: https://github.com/owner/keyless/.github/workflows/release.yml@*\n"},
+		{name: "another workflow", keys: attestationRoots + "repos:\n  owner/keyless:\n    workflow: https://github.com/owner/keyless/.github/workflows/nightly.yml@*\n", want: "invalid"},
+		{name: "a shared workflow of another repo", keys: attestationRoots + "repos:\n  owner/keyless:\n    workflow: https://github.com/owner/shared/.github/workflows/release.yml@*\n", want: "invalid"},
+		{name: "another fulcio root", keys: "sigstore:\n  fulcio_roots: [testdata/fulcio.pem]\n  rekor_keys: [testdata/attestation_rekor.pub]\nrepos: {}\n", want: "invalid"},
+		{name: "another rekor key", keys: "sigstore:\n  fulcio_roots: [testdata/attestation_fulcio.pem]\n  rekor_keys: [testdata/rekor.pub]\nrepos: {}\n", want: "invalid"},
+		{name: "no rekor key", keys: "sigstore:\n  fulcio_roots: [testdata/attestation_fulcio.pem]\nrepos: {}\n", want: "no key"},
+		{name: "no fulcio root", keys: "repos: {}\n", want: "no key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := loadKeys(t, tt.keys)
+			p, err := k.VerifyAttestation("owner/keyless", assetDigest(t), readTestdata(t, "attestation.json"))
+			if got := outcome(err); got != tt.want {
+				t.Fatalf("VerifyAttestation() = %+v, %v, want %q", p, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPAE(t *testing.T) {
+	// the example of the DSSE specification
+	got := string(pae("http://example.com/HelloWorld", []byte("hello world")))
+	want := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+	if got != want {
+		t.Errorf("pae() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aead.dev/minisign"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+Keys is the trusted-keys file, the keys the signatures and attestations of each repo are verified with:
+
+	sigstore:
+	  fulcio_roots: [fulcio.pem]   # the certificates keyless signatures chain up to
+	  rekor_keys: [rekor.pub]      # the keys of the transparency log
+	repos:
+	  jedisct1/minisign:
+	    minisign: RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3
+	  sharkdp/bat:
+	    gpg: bat.asc               # an armored or binary keyring
+	  sigstore/cosign:
+	    cosign: cosign.pub         # a PEM public key (ecdsa, rsa or ed25519)
+	  cli/cli:
+	    identity: https://github.com/cli/cli/.github/workflows/deployment.yml@*
+	    issuer: https://token.actions.githubusercontent.com
+	  owner/tool:
+	    workflow: https://github.com/owner/shared/.github/workflows/release.yml@*
+
+Every key is either a file (relative to the trusted-keys file) or the key itself.
+Nothing is fetched: keyless signatures are verified against the pinned
+Fulcio roots and Rekor keys only.
+*/
+type Keys struct {
+	Sigstore Sigstore            `yaml:"sigstore"`
+	Repos    map[string]RepoKeys `yaml:"repos"` // owner/repo -> keys
+
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	rekor         []crypto.PublicKey
+}
+
+// Sigstore are the pinned roots of the keyless (Fulcio) signatures
+type Sigstore struct {
+	FulcioRoots []string `yaml:"fulcio_roots"`
+	RekorKeys   []string `yaml:"rekor_keys"`
+}
+
+// RepoKeys are the keys trusted to sign the releases of a repo
+type RepoKeys struct {
+	Cosign   string `yaml:"cosign"`
+	Minisign string `yaml:"minisign"`
+	GPG      string `yaml:"gpg"`
+	// the identity (certificate SAN, * matches anything) and the OIDC issuer of keyless signatures
+	Identity string `yaml:"identity"`
+	Issuer   string `yaml:"issuer"`
+	// the workflow trusted to build the attested assets (* matches anything),
+	// by default any workflow of the repo itself
+	Workflow string `yaml:"workflow"`
+
+	cosign   crypto.PublicKey
+	minisign *minisign.PublicKey
+	gpg      openpgp.EntityList
+}
+
+// Load the trusted-keys file at path and parse every key in it. Unknown keys are errors
+func LoadKeys(path string) (*Keys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keys{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(k); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := k.parse(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return k, nil
+}
+
+func (k *Keys) parse(dir string) error {
+	k.roots, k.intermediates = x509.NewCertPool(), x509.NewCertPool()
+	for _, v := range k.Sigstore.FulcioRoots {
+		certs, err := parseCerts(keyValue(dir, v))
+		if err != nil {
+			return fmt.Errorf("fulcio_roots: %w", err)
+		}
+		for _, c := range certs {
+			if bytes.Equal(c.RawIssuer, c.RawSubject) {
+				k.roots.AddCert(c)
+			} else {
+				k.intermediates.AddCert(c)
+			}
+		}
+	}
+	for _, v := range k.Sigstore.RekorKeys {
+		pub, err := parsePublicKey(keyValue(dir, v))
+		if err != nil {
+			return fmt.Errorf("rekor_keys: %w", err)
+		}
+		k.rekor = append(k.rekor, pub)
+	}
+
+	for repo, rk := range k.Repos {
+		if parts := strings.Split(repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("repo %q is not in the owner/repo format", repo)
+		}
+		var err error
+		if rk.Cosign != "" {
+			if rk.cosign, err = parsePublicKey(keyValue(dir, rk.Cosign)); err != nil {
+				return fmt.Errorf("%s: cosign: %w", repo, err)
+			}
+		}
+		if rk.Minisign != "" {
+			rk.minisign = &minisign.PublicKey{}
+			if err := rk.minisign.UnmarshalText(lastLine(keyValue(dir, rk.Minisign))); err != nil {
+				return fmt.Errorf("%s: minisign: %w", repo, err)
+			}
+		}
+		if rk.GPG != "" {
+			if rk.gpg, err = parseKeyRing(keyValue(dir, rk.GPG)); err != nil {
+				return fmt.Errorf("%s: gpg: %w", repo, err)
+			}
+		}
+		if rk.Identity != "" && len(k.Sigstore.FulcioRoots) == 0 {
+			return fmt.Errorf("%s: an identity needs the fulcio_roots of sigstore", repo)
+		}
+		if rk.Cosign == "" && rk.Minisign == "" && rk.GPG == "" && rk.Identity == "" && rk.Workflow == "" {
+			return fmt.Errorf("%s: no key", repo)
+		}
+		k.Repos[repo] = rk
+	}
+	return nil
+}
+
+// Return the content of the file v names (relative to dir), or v itself when it's not a file
+func keyValue(dir, v string) []byte {
+	if !strings.Contains(v, "\n") {
+		p := v
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		if data, err := os.ReadFile(p); err == nil {
+			return data
+		}
+	}
+	return []byte(v)
+}
+
+// The key of a minisign public key file is its last line, after the untrusted comment
+func lastLine(data []byte) []byte {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	return []byte(strings.TrimSpace(lines[len(lines)-1]))
+}
+
+// Parse a PEM public key, or the public key of a PEM certificate
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("not a PEM public key")
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// Parse every certificate of a PEM file
+func parseCerts(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate")
+	}
+	return certs, nil
+}
+
+// Parse an armored or a binary OpenPGP keyring
+func parseKeyRing(data []byte) (openpgp.EntityList, error) {
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
@@ -0,0 +1,282 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// the Fulcio certificate extensions of the OIDC issuer: the raw one, and the DER encoded one
+var (
+	oidIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// bundle is the bundle written by cosign sign-blob --bundle
+type bundle struct {
+	Base64Signature string `json:"base64Signature"`
+	Cert            string `json:"cert"` // base64 of the PEM certificate, empty when signed with a key
+	RekorBundle     *struct {
+		SignedEntryTimestamp string       `json:"SignedEntryTimestamp"`
+		Payload              rekorPayload `json:"Payload"`
+	} `json:"rekorBundle"`
+}
+
+// rekorPayload is the transparency log entry, its fields in the order of its canonical JSON
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// rekorEntry is a transparency log entry as the Rekor api returns it
+type rekorEntry struct {
+	rekorPayload
+	Verification struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// rekorBody is the part of a hashedrekord (or rekord) entry that binds it to the signature
+type rekorBody struct {
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+/*
+- Verify a cosign bundle of data.
+
+- Signed with a key, the signature is verified with the cosign key of the repo.
+Keyless, the certificate must chain up to the pinned Fulcio roots at the time
+the transparency log recorded it, and be issued to the identity (and issuer)
+of the repo. Either way, when the bundle has a transparency log entry,
+it must be signed by a pinned Rekor key and record this very signature and data.
+*/
+func (k *Keys) verifyBundle(rk RepoKeys, repo string, data io.ReadSeeker, raw []byte) (string, error) {
+	var b bundle
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return "", fmt.Errorf("invalid cosign bundle: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(b.Base64Signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid cosign bundle: %w", err)
+	}
+
+	if b.Cert == "" {
+		if rk.cosign == nil {
+			return "", fmt.Errorf("%w: no cosign key for %s", ErrNoKey, repo)
+		}
+		if err := verifyReader(rk.cosign, data, sig); err != nil {
+			return "", fmt.Errorf("invalid cosign signature: %w", err)
+		}
+		if b.RekorBundle != nil {
+			if _, err := k.verifyEntry(b.RekorBundle.Payload, decodeBase64([]byte(b.RekorBundle.SignedEntryTimestamp)), data, sig, nil); err != nil {
+				return "", err
+			}
+		}
+		return "cosign", nil
+	}
+
+	if rk.Identity == "" {
+		return "", fmt.Errorf("%w: no identity for %s", ErrNoKey, repo)
+	}
+	// a certificate is only valid for the few minutes after it was issued,
+	// the transparency log entry proves the signature was made then
+	if b.RekorBundle == nil {
+		return "", errors.New("keyless cosign bundle without a transparency log entry")
+	}
+	certPEM := decodeBase64([]byte(b.Cert))
+	certs, err := parseCerts(certPEM)
+	if err != nil {
+		return "", fmt.Errorf("invalid cosign bundle: %w", err)
+	}
+	cert := certs[0]
+
+	integrated, err := k.verifyEntry(b.RekorBundle.Payload, decodeBase64([]byte(b.RekorBundle.SignedEntryTimestamp)), data, sig, certPEM)
+	if err != nil {
+		return "", err
+	}
+	if err := k.verifyCert(cert, integrated); err != nil {
+		return "", err
+	}
+	identity, err := checkIdentity(cert, rk)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyReader(cert.PublicKey, data, sig); err != nil {
+		return "", fmt.Errorf("invalid cosign signature: %w", err)
+	}
+	return "sigstore " + identity, nil
+}
+
+/*
+- Verify the signed entry timestamp set of a transparency log entry (of a bundle,
+or from the Rekor api) with the pinned Rekor keys, and check the entry records
+the signature, the sha256 of data and the certificate (when it's keyless).
+
+- Return the time the entry was recorded.
+*/
+func (k *Keys) verifyEntry(payload rekorPayload, set []byte, data io.ReadSeeker, sig, certPEM []byte) (time.Time, error) {
+	if err := k.verifySET(payload, set); err != nil {
+		return time.Time{}, err
+	}
+
+	var body rekorBody
+	rawBody, err := base64.StdEncoding.DecodeString(payload.Body)
+	if err == nil {
+		err = json.Unmarshal(rawBody, &body)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid transparency log entry: %w", err)
+	}
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return time.Time{}, err
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(sum, data); err != nil {
+		return time.Time{}, err
+	}
+	switch {
+	case body.Spec.Data.Hash.Algorithm != "sha256" || body.Spec.Data.Hash.Value != hex.EncodeToString(sum.Sum(nil)):
+		return time.Time{}, errors.New("the transparency log entry is for another file")
+	case !bytes.Equal(decodeBase64([]byte(body.Spec.Signature.Content)), sig):
+		return time.Time{}, errors.New("the transparency log entry is for another signature")
+	case certPEM != nil && !bytes.Equal(bytes.TrimSpace(decodeBase64([]byte(body.Spec.Signature.PublicKey.Content))), bytes.TrimSpace(certPEM)):
+		return time.Time{}, errors.New("the transparency log entry is for another certificate")
+	}
+	return time.Unix(payload.IntegratedTime, 0), nil
+}
+
+/*
+- Verify the transparency log entries of a keyless signature, as the Rekor api returns them
+(a list of maps of the uuid of an entry to the entry), see verifyEntry.
+
+- Return the time the first entry that verifies was recorded, or the error of the last one.
+An error wrapping ErrNoKey is returned when there's no entry.
+*/
+func (k *Keys) verifyEntries(raw []byte, data io.ReadSeeker, sig, certPEM []byte) (time.Time, error) {
+	var entries []map[string]rekorEntry
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return time.Time{}, fmt.Errorf("invalid transparency log entries: %w", err)
+		}
+	}
+	err := fmt.Errorf("%w: no transparency log entry for the signature", ErrNoKey)
+	for _, byUUID := range entries {
+		for _, e := range byUUID {
+			set := decodeBase64([]byte(e.Verification.SignedEntryTimestamp))
+			var integrated time.Time
+			if integrated, err = k.verifyEntry(e.rekorPayload, set, data, sig, certPEM); err == nil {
+				return integrated, nil
+			}
+		}
+	}
+	return time.Time{}, err
+}
+
+// Verify the signed entry timestamp of a transparency log entry with the pinned Rekor keys
+func (k *Keys) verifySET(payload rekorPayload, set []byte) error {
+	if len(k.rekor) == 0 {
+		return fmt.Errorf("%w: no rekor_keys to verify the transparency log entry", ErrNoKey)
+	}
+	canonical, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	for _, pub := range k.rekor {
+		if verifyBlob(pub, canonical, set) == nil {
+			return nil
+		}
+	}
+	return errors.New("transparency log entry not signed by the rekor_keys")
+}
+
+// Verify a Fulcio certificate chains up to the pinned roots at the time it was used
+func (k *Keys) verifyCert(cert *x509.Certificate, at time.Time) error {
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         k.roots,
+		Intermediates: k.intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return fmt.Errorf("certificate not issued by the fulcio roots: %w", err)
+	}
+	return nil
+}
+
+// Check the certificate is issued to the identity and issuer of the repo, return the identity
+func checkIdentity(cert *x509.Certificate, rk RepoKeys) (string, error) {
+	if rk.Issuer != "" {
+		if issuer := certIssuer(cert); issuer != rk.Issuer {
+			return "", fmt.Errorf("certificate issued by %q, not %q", issuer, rk.Issuer)
+		}
+	}
+
+	var sans []string
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, san := range sans {
+		if globMatch(rk.Identity, san) {
+			return san, nil
+		}
+	}
+	return "", fmt.Errorf("certificate issued to %s, not %s", strings.Join(sans, ", "), rk.Identity)
+}
+
+// Return the OIDC issuer of a Fulcio certificate
+func certIssuer(cert *x509.Certificate) string {
+	if issuer := certExtension(cert, oidIssuerV2); issuer != "" {
+		return issuer
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidIssuer) {
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
+
+// Return the value of a DER encoded string extension of a Fulcio certificate, empty if it has none
+func certExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) string {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			var value string
+			if _, err := asn1.Unmarshal(ext.Value, &value); err == nil {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// Match s against a pattern where * matches anything, slashes included
+func globMatch(pattern, s string) bool {
+	re := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	return regexp.MustCompile("^" + re + "$").MatchString(s)
+}
@@ -0,0 +1,353 @@
+//go:build ignore
+
+// Generate the fixtures of the verify tests (from verify/testdata): go run gen.go
+// for the signatures, go run gen.go -attestation for the attestation, with its own
+// fulcio root and rekor key. Each only writes its own files.
+// Every key is thrown away once its signatures are made.
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"aead.dev/minisign"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+var asset = []byte("getghrel test asset\n")
+
+// when the keyless certificate was issued, and when the transparency log recorded its signature
+var (
+	issued     = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	integrated = issued.Add(time.Minute)
+)
+
+// the Fulcio extensions of the OIDC issuer (DER encoded) and of the workflow
+var (
+	oidIssuerV2         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	oidBuildSignerURI   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 9}
+	oidSourceRepoURI    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 12}
+	oidSourceRepoDigest = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 13}
+)
+
+const workflow = "https://github.com/owner/keyless/.github/workflows/release.yml@refs/tags/v1.0.0"
+
+// the id of the transparency log, the hex of the sha256 of its key
+const logID = "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d"
+
+func write(name string, data []byte) {
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func check(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ecKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	check(err)
+	return key
+}
+
+func pemPublicKey(key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	check(err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func signBlob(key *ecdsa.PrivateKey, data []byte) []byte {
+	sum := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	check(err)
+	return sig
+}
+
+func der(s string) []byte {
+	v, err := asn1.Marshal(s)
+	check(err)
+	return v
+}
+
+// a self-signed root certificate, saved in the file name
+func newRoot(name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key := ecKey()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test " + name},
+		NotBefore:             time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	check(err)
+	root, err := x509.ParseCertificate(rootDER)
+	check(err)
+	write(name, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}))
+	return root, key
+}
+
+// a Fulcio root, saved in the file name, and a certificate it issued to the workflow
+func fulcio(name string) (root *x509.Certificate, rootKey *ecdsa.PrivateKey, leafKey *ecdsa.PrivateKey, leaf []byte) {
+	root, rootKey = newRoot(name)
+
+	leafKey = ecKey()
+	u, err := url.Parse(workflow)
+	check(err)
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    issued,
+		NotAfter:     issued.Add(10 * time.Minute),
+		URIs:         []*url.URL{u},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidIssuerV2, Value: der("https://token.actions.githubusercontent.com")},
+			{Id: oidBuildSignerURI, Value: der(workflow)},
+			{Id: oidSourceRepoURI, Value: der("https://github.com/owner/keyless")},
+			{Id: oidSourceRepoDigest, Value: der("0123456789abcdef0123456789abcdef01234567")},
+		},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, root, &leafKey.PublicKey, rootKey)
+	check(err)
+	return root, rootKey, leafKey, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+}
+
+// rekorPayload is the transparency log entry, its fields in the order of its canonical JSON
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// a cosign sign-blob bundle of asset, recorded in the transparency log signed by rekorKey
+func cosignBundle(rekorKey *ecdsa.PrivateKey, sig, certPEM []byte) []byte {
+	sum := sha256.Sum256(asset)
+	var body struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Spec       struct {
+			Data struct {
+				Hash struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"hash"`
+			} `json:"data"`
+			Signature struct {
+				Content   string `json:"content"`
+				PublicKey struct {
+					Content string `json:"content"`
+				} `json:"publicKey"`
+			} `json:"signature"`
+		} `json:"spec"`
+	}
+	body.APIVersion, body.Kind = "0.0.1", "hashedrekord"
+	body.Spec.Data.Hash.Algorithm = "sha256"
+	body.Spec.Data.Hash.Value = hex.EncodeToString(sum[:])
+	body.Spec.Signature.Content = base64.StdEncoding.EncodeToString(sig)
+	body.Spec.Signature.PublicKey.Content = base64.StdEncoding.EncodeToString(certPEM)
+	rawBody, err := json.Marshal(body)
+	check(err)
+
+	payload := rekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(rawBody),
+		IntegratedTime: integrated.Unix(),
+		LogID:          logID,
+		LogIndex:       42,
+	}
+	canonical, err := json.Marshal(payload)
+	check(err)
+
+	b := map[string]any{
+		"base64Signature": base64.StdEncoding.EncodeToString(sig),
+		"rekorBundle": map[string]any{
+			"SignedEntryTimestamp": base64.StdEncoding.EncodeToString(signBlob(rekorKey, canonical)),
+			"Payload":              payload,
+		},
+	}
+	if certPEM != nil {
+		b["cert"] = base64.StdEncoding.EncodeToString(certPEM)
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	check(err)
+	return data
+}
+
+// the SLSA provenance of asset, built by the workflow from owner/keyless
+func provenance() []byte {
+	sum := sha256.Sum256(asset)
+	s := map[string]any{
+		"_type": "https://in-toto.io/Statement/v1",
+		"subject": []any{
+			map[string]any{"name": "asset.txt", "digest": map[string]string{"sha256": hex.EncodeToString(sum[:])}},
+		},
+		"predicateType": "https://slsa.dev/provenance/v1",
+		"predicate": map[string]any{
+			"buildDefinition": map[string]any{
+				"externalParameters": map[string]any{
+					"workflow": map[string]string{
+						"repository": "https://github.com/owner/keyless",
+						"path":       ".github/workflows/release.yml",
+						"ref":        "refs/tags/v1.0.0",
+					},
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(s)
+	check(err)
+	return data
+}
+
+// a github attestation of asset: a sigstore bundle (v0.3) of the DSSE envelope of its provenance,
+// signed by leafKey and recorded in the transparency log signed by rekorKey
+func attestation(rekorKey, leafKey *ecdsa.PrivateKey, certPEM []byte) []byte {
+	const payloadType = "application/vnd.in-toto+json"
+	payload := provenance()
+	pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+	sig := signBlob(leafKey, []byte(pae))
+
+	var body struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Spec       struct {
+			PayloadHash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"payloadHash"`
+			Signatures []map[string]string `json:"signatures"`
+		} `json:"spec"`
+	}
+	sum := sha256.Sum256(payload)
+	body.APIVersion, body.Kind = "0.0.1", "dsse"
+	body.Spec.PayloadHash.Algorithm = "sha256"
+	body.Spec.PayloadHash.Value = hex.EncodeToString(sum[:])
+	body.Spec.Signatures = []map[string]string{{
+		"signature": base64.StdEncoding.EncodeToString(sig),
+		"verifier":  base64.StdEncoding.EncodeToString(certPEM),
+	}}
+	rawBody, err := json.Marshal(body)
+	check(err)
+
+	entry := rekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(rawBody),
+		IntegratedTime: integrated.Unix(),
+		LogID:          logID,
+		LogIndex:       43,
+	}
+	canonical, err := json.Marshal(entry)
+	check(err)
+	keyID, err := hex.DecodeString(logID)
+	check(err)
+	block, _ := pem.Decode(certPEM)
+
+	b := map[string]any{
+		"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+		"verificationMaterial": map[string]any{
+			"certificate": map[string]string{"rawBytes": base64.StdEncoding.EncodeToString(block.Bytes)},
+			"tlogEntries": []any{map[string]any{
+				"logIndex":          strconv.FormatInt(entry.LogIndex, 10),
+				"logId":             map[string]string{"keyId": base64.StdEncoding.EncodeToString(keyID)},
+				"kindVersion":       map[string]string{"kind": "dsse", "version": "0.0.1"},
+				"integratedTime":    strconv.FormatInt(entry.IntegratedTime, 10),
+				"inclusionPromise":  map[string]string{"signedEntryTimestamp": base64.StdEncoding.EncodeToString(signBlob(rekorKey, canonical))},
+				"canonicalizedBody": entry.Body,
+			}},
+		},
+		"dsseEnvelope": map[string]any{
+			"payload":     base64.StdEncoding.EncodeToString(payload),
+			"payloadType": payloadType,
+			"signatures":  []map[string]string{{"sig": base64.StdEncoding.EncodeToString(sig)}},
+		},
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	check(err)
+	return data
+}
+
+// the github attestation of asset, signed by the same workflow
+func attestationFixtures() {
+	rekorKey := ecKey()
+	write("attestation_rekor.pub", pemPublicKey(rekorKey))
+	_, _, leafKey, leaf := fulcio("attestation_fulcio.pem")
+	write("attestation.json", attestation(rekorKey, leafKey, leaf))
+}
+
+func main() {
+	onlyAttestation := flag.Bool("attestation", false, "only write the attestation fixtures")
+	flag.Parse()
+	if *onlyAttestation {
+		attestationFixtures()
+		return
+	}
+
+	write("asset.txt", asset)
+
+	// minisign, and another key that didn't sign it
+	mpub, mpriv, err := minisign.GenerateKey(rand.Reader)
+	check(err)
+	write("minisign.pub", []byte("untrusted comment: minisign public key\n"+mpub.String()+"\n"))
+	write("asset.txt.minisig", minisign.Sign(mpriv, asset))
+	other, _, err := minisign.GenerateKey(rand.Reader)
+	check(err)
+	write("other_minisign.pub", []byte(other.String()+"\n"))
+
+	// gpg: an armored and a binary signature
+	entity, err := openpgp.NewEntity("getghrel test", "", "test@example.com", nil)
+	check(err)
+	var pub bytes.Buffer
+	w, err := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	check(err)
+	check(entity.Serialize(w))
+	check(w.Close())
+	write("gpg.asc", pub.Bytes())
+	var sig bytes.Buffer
+	check(openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(asset), nil))
+	write("asset.txt.asc", sig.Bytes())
+	sig.Reset()
+	check(openpgp.DetachSign(&sig, entity, bytes.NewReader(asset), nil))
+	write("asset.txt.gpgsig", sig.Bytes())
+
+	// cosign key: a .sig, and a bundle recorded in the transparency log
+	rekorKey := ecKey()
+	write("rekor.pub", pemPublicKey(rekorKey))
+	cosignKey := ecKey()
+	write("cosign.pub", pemPublicKey(cosignKey))
+	cosignSig := signBlob(cosignKey, asset)
+	write("asset.txt.sig", []byte(base64.StdEncoding.EncodeToString(cosignSig)))
+	write("cosign.bundle", cosignBundle(rekorKey, cosignSig, nil))
+
+	// keyless: a .sig and its .pem (base64 of the PEM, like goreleaser), and a bundle
+	// another root, that didn't issue it
+	newRoot("other_fulcio.pem")
+	_, _, leafKey, leaf := fulcio("fulcio.pem")
+	keylessSig := signBlob(leafKey, asset)
+	write("keyless.sig", []byte(base64.StdEncoding.EncodeToString(keylessSig)))
+	write("keyless.pem", []byte(base64.StdEncoding.EncodeToString(leaf)))
+	write("keyless.bundle", cosignBundle(rekorKey, keylessSig, leaf))
+}
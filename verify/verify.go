@@ -0,0 +1,245 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"aead.dev/minisign"
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// Suffixes are the suffixes of the signature files verified, in the order they're tried:
+// bat.tar.gz.minisig, bat.tar.gz.asc, bat.tar.gz.sig (cosign, or a binary gpg signature)
+// and bat.tar.gz.bundle (cosign bundle, keyless or signed with a key)
+var Suffixes = []string{".minisig", ".asc", ".sig", ".bundle"}
+
+// CertSuffixes are the suffixes of the certificate of a keyless cosign .sig:
+// bat.tar.gz.sig is signed with the key of bat.tar.gz.pem (or bat.tar.gz.crt)
+var CertSuffixes = []string{".pem", ".crt"}
+
+// ErrNoKey is returned when there's no trusted key to verify a signature with
+var ErrNoKey = errors.New("no trusted key")
+
+/*
+- Verify the signature file sig (named like data + suffix) of data,
+signed for repo (owner/repo), with the keys trusted for it.
+
+- data is read from its start, as many times as needed: it's not loaded
+in memory (but for legacy minisign and ed25519 cosign signatures, of the data itself).
+
+- The returned string says what verified it: "minisign", "gpg", "cosign" or
+"sigstore <identity>". An error wrapping ErrNoKey means it can't be verified,
+any other error means the signature is not a valid one.
+*/
+func (k *Keys) Verify(repo, suffix string, data io.ReadSeeker, sig []byte) (string, error) {
+	rk, ok := k.Repos[repo]
+	if !ok {
+		return "", fmt.Errorf("%w for %s", ErrNoKey, repo)
+	}
+
+	switch suffix {
+	case ".minisig":
+		if rk.minisign == nil {
+			return "", fmt.Errorf("%w: no minisign key for %s", ErrNoKey, repo)
+		}
+		if err := checkMinisign(*rk.minisign, data, sig); err != nil {
+			return "", err
+		}
+		return "minisign", nil
+
+	case ".asc":
+		if rk.gpg == nil {
+			return "", fmt.Errorf("%w: no gpg key for %s", ErrNoKey, repo)
+		}
+		if err := checkGPG(rk.gpg, data, sig); err != nil {
+			return "", err
+		}
+		return "gpg", nil
+
+	case ".sig":
+		// cosign signatures are base64, gpg ones are binary
+		switch {
+		case rk.cosign != nil:
+			if err := verifyReader(rk.cosign, data, decodeBase64(sig)); err != nil {
+				return "", fmt.Errorf("invalid cosign signature: %w", err)
+			}
+			return "cosign", nil
+		case rk.gpg != nil:
+			if err := checkGPG(rk.gpg, data, sig); err != nil {
+				return "", err
+			}
+			return "gpg", nil
+		}
+		return "", fmt.Errorf("%w: no cosign or gpg key for %s", ErrNoKey, repo)
+
+	case ".bundle":
+		return k.verifyBundle(rk, repo, data, sig)
+	}
+	return "", fmt.Errorf("%w: %s signatures are not supported", ErrNoKey, suffix)
+}
+
+/*
+- Verify a keyless cosign signature sig of data, and the certificate
+of its key (the content of a .pem or .crt file, PEM or base64 of PEM),
+signed for repo (owner/repo).
+
+- entries are the transparency log entries of data, as the Rekor api returns them
+(POST /api/v1/log/entries/retrieve). A certificate is only valid for the few minutes
+after it was issued: one of the entries must be signed by a pinned Rekor key
+and record this very signature, data and certificate, and the certificate must chain up
+to the pinned Fulcio roots at the time it was recorded, like in a bundle.
+It must be issued to the identity (and issuer) of the repo.
+Without any entry, an error wrapping ErrNoKey is returned.
+*/
+func (k *Keys) VerifyKeyless(repo string, data io.ReadSeeker, sig, certFile, entries []byte) (string, error) {
+	rk, ok := k.Repos[repo]
+	switch {
+	case !ok:
+		return "", fmt.Errorf("%w for %s", ErrNoKey, repo)
+	case rk.Identity == "":
+		return "", fmt.Errorf("%w: no identity for %s", ErrNoKey, repo)
+	}
+
+	certPEM := decodeBase64(certFile)
+	certs, err := parseCerts(certPEM)
+	if err != nil {
+		return "", fmt.Errorf("invalid certificate: %w", err)
+	}
+	cert := certs[0]
+	sig = decodeBase64(sig)
+
+	integrated, err := k.verifyEntries(entries, data, sig, certPEM)
+	if err != nil {
+		return "", err
+	}
+	if err := k.verifyCert(cert, integrated); err != nil {
+		return "", err
+	}
+	identity, err := checkIdentity(cert, rk)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyReader(cert.PublicKey, data, sig); err != nil {
+		return "", fmt.Errorf("invalid cosign signature: %w", err)
+	}
+	return "sigstore " + identity, nil
+}
+
+// Check a minisign signature, prehashed (streamed) or legacy (of the data itself)
+func checkMinisign(pub minisign.PublicKey, data io.ReadSeeker, sig []byte) error {
+	var s minisign.Signature
+	if err := s.UnmarshalText(sig); err != nil {
+		return fmt.Errorf("invalid minisign signature: %w", err)
+	}
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var ok bool
+	if s.Algorithm == minisign.HashEdDSA {
+		r := minisign.NewReader(data)
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return err
+		}
+		ok = r.Verify(pub, sig)
+	} else {
+		message, err := io.ReadAll(data)
+		if err != nil {
+			return err
+		}
+		ok = minisign.Verify(pub, message, sig)
+	}
+	if !ok {
+		return errors.New("invalid minisign signature")
+	}
+	return nil
+}
+
+// Check an armored or binary detached gpg signature
+func checkGPG(keyring openpgp.EntityList, data io.ReadSeeker, sig []byte) error {
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var err error
+	if bytes.Contains(sig, []byte("-----BEGIN PGP")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, data, bytes.NewReader(sig), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, data, bytes.NewReader(sig), nil)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid gpg signature: %w", err)
+	}
+	return nil
+}
+
+// Decode a base64 signature, or return it as it is when it's not base64
+func decodeBase64(sig []byte) []byte {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return sig
+	}
+	return decoded
+}
+
+// Verify the signature of data with a public key, see verifyReader
+func verifyBlob(pub crypto.PublicKey, data, sig []byte) error {
+	return verifyReader(pub, bytes.NewReader(data), sig)
+}
+
+/*
+- Verify the signature of data, read from its start, with a public key,
+the way cosign signs blobs: ecdsa over the sha256 of data (sha384 and sha512
+for P-384 and P-521), rsa PKCS#1 v1.5 over its sha256, or ed25519 over data itself.
+*/
+func verifyReader(pub crypto.PublicKey, data io.ReadSeeker, sig []byte) error {
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		var h hash.Hash
+		switch pub.Curve {
+		case elliptic.P384():
+			h = sha512.New384()
+		case elliptic.P521():
+			h = sha512.New()
+		default:
+			h = sha256.New()
+		}
+		if _, err := io.Copy(h, data); err != nil {
+			return err
+		}
+		if !ecdsa.VerifyASN1(pub, h.Sum(nil), sig) {
+			return errors.New("ecdsa verification failed")
+		}
+		return nil
+	case *rsa.PublicKey:
+		h := sha256.New()
+		if _, err := io.Copy(h, data); err != nil {
+			return err
+		}
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, h.Sum(nil), sig)
+	case ed25519.PublicKey:
+		message, err := io.ReadAll(data)
+		if err != nil {
+			return err
+		}
+		if !ed25519.Verify(pub, message, sig) {
+			return errors.New("ed25519 verification failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("%T keys are not supported", pub)
+}
@@ -0,0 +1,344 @@
+package verify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the fixtures are made by testdata/gen.go, the repos of testdata/keys.yaml are:
+// owner/minisign, owner/gpg, owner/cosign and owner/keyless (the keys that signed testdata/asset.txt),
+// owner/other (another minisign key), owner/wrongid and owner/wrongissuer (keyless, another identity)
+const workflow = "https://github.com/owner/keyless/.github/workflows/release.yml@refs/tags/v1.0.0"
+
+func testKeys(t *testing.T) *Keys {
+	t.Helper()
+	k, err := LoadKeys(filepath.Join("testdata", "keys.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// the outcome of a verification: "" when it verified, "no key" for ErrNoKey, "invalid" otherwise
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNoKey):
+		return "no key"
+	}
+	return "invalid"
+}
+
+func TestVerify(t *testing.T) {
+	k := testKeys(t)
+	tampered := []byte("getghrel test asset!\n")
+
+	tests := []struct {
+		name     string
+		repo     string
+		suffix   string
+		sig      string
+		tampered bool
+		by       string
+		want     string
+	}{
+		{name: "minisign", repo: "owner/minisign", suffix: ".minisig", sig: "asset.txt.minisig", by: "minisign"},
+		{name: "minisign, tampered", repo: "owner/minisign", suffix: ".minisig", sig: "asset.txt.minisig", tampered: true, want: "invalid"},
+		{name: "minisign, another key", repo: "owner/other", suffix: ".minisig", sig: "asset.txt.minisig", want: "invalid"},
+		{name: "minisign, no minisign key", repo: "owner/gpg", suffix: ".minisig", sig: "asset.txt.minisig", want: "no key"},
+		{name: "gpg armored", repo: "owner/gpg", suffix: ".asc", sig: "asset.txt.asc", by: "gpg"},
+		{name: "gpg armored, tampered", repo: "owner/gpg", suffix: ".asc", sig: "asset.txt.asc", tampered: true, want: "invalid"},
+		{name: "gpg binary", repo: "owner/gpg", suffix: ".sig", sig: "asset.txt.gpgsig", by: "gpg"},
+		{name: "gpg binary, tampered", repo: "owner/gpg", suffix: ".sig", sig: "asset.txt.gpgsig", tampered: true, want: "invalid"},
+		{name: "gpg, no gpg key", repo: "owner/minisign", suffix: ".asc", sig: "asset.txt.asc", want: "no key"},
+		{name: "cosign", repo: "owner/cosign", suffix: ".sig", sig: "asset.txt.sig", by: "cosign"},
+		{name: "cosign, tampered", repo: "owner/cosign", suffix: ".sig", sig: "asset.txt.sig", tampered: true, want: "invalid"},
+		{name: "cosign, signed by another key", repo: "owner/cosign", suffix: ".sig", sig: "keyless.sig", want: "invalid"},
+		{name: "cosign, no cosign key", repo: "owner/keyless", suffix: ".sig", sig: "asset.txt.sig", want: "no key"},
+		{name: "cosign bundle", repo: "owner/cosign", suffix: ".bundle", sig: "cosign.bundle", by: "cosign"},
+		{name: "cosign bundle, tampered", repo: "owner/cosign", suffix: ".bundle", sig: "cosign.bundle", tampered: true, want: "invalid"},
+		{name: "keyless bundle", repo: "owner/keyless", suffix: ".bundle", sig: "keyless.bundle", by: "sigstore " + workflow},
+		{name: "keyless bundle, tampered", repo: "owner/keyless", suffix: ".bundle", sig: "keyless.bundle", tampered: true, want: "invalid"},
+		{name: "keyless bundle, another identity", repo: "owner/wrongid", suffix: ".bundle", sig: "keyless.bundle", want: "invalid"},
+		{name: "keyless bundle, another issuer", repo: "owner/wrongissuer", suffix: ".bundle", sig: "keyless.bundle", want: "invalid"},
+		{name: "keyless bundle, no identity", repo: "owner/cosign", suffix: ".bundle", sig: "keyless.bundle", want: "no key"},
+		{name: "unknown repo", repo: "owner/unknown", suffix: ".minisig", sig: "asset.txt.minisig", want: "no key"},
+		{name: "unsupported suffix", repo: "owner/minisign", suffix: ".p7s", sig: "asset.txt.minisig", want: "no key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := readTestdata(t, "asset.txt")
+			if tt.tampered {
+				data = tampered
+			}
+			by, err := k.Verify(tt.repo, tt.suffix, bytes.NewReader(data), readTestdata(t, tt.sig))
+			if got := outcome(err); got != tt.want {
+				t.Fatalf("Verify() = %q, %v, want %q", by, err, tt.want)
+			}
+			if by != tt.by {
+				t.Errorf("Verify() = %q, want %q", by, tt.by)
+			}
+		})
+	}
+}
+
+// Return transparency log entries of keyless.sig as the Rekor api returns them:
+// the one of keyless.bundle (the same signature) changed by each edit, nil to keep it
+func rekorResponse(t *testing.T, edits ...func(e *rekorEntry)) []byte {
+	t.Helper()
+	var b bundle
+	if err := json.Unmarshal(readTestdata(t, "keyless.bundle"), &b); err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]rekorEntry
+	for i, edit := range edits {
+		var e rekorEntry
+		e.rekorPayload = b.RekorBundle.Payload
+		e.Verification.SignedEntryTimestamp = b.RekorBundle.SignedEntryTimestamp
+		if edit != nil {
+			edit(&e)
+		}
+		entries = append(entries, map[string]rekorEntry{fmt.Sprintf("uuid%d", i): e})
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifyKeyless(t *testing.T) {
+	k := testKeys(t)
+	pemFile := readTestdata(t, "keyless.pem")
+	plainPEM, err := base64.StdEncoding.DecodeString(string(pemFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := rekorResponse(t, nil)
+	forged := func(e *rekorEntry) { e.LogIndex++ }
+
+	tests := []struct {
+		name    string
+		repo    string
+		data    string
+		sig     string
+		cert    []byte
+		entries []byte
+		by      string
+		want    string
+	}{
+		{name: "base64 certificate", repo: "owner/keyless", sig: "keyless.sig", cert: pemFile, entries: entries, by: "sigstore " + workflow},
+		{name: "PEM certificate", repo: "owner/keyless", sig: "keyless.sig", cert: plainPEM, entries: entries, by: "sigstore " + workflow},
+		{name: "a valid entry after an invalid one", repo: "owner/keyless", sig: "keyless.sig", cert: pemFile, entries: rekorResponse(t, forged, nil), by: "sigstore " + workflow},
+		{name: "no transparency log entry", repo: "owner/keyless", sig: "keyless.sig", cert: pemFile, want: "no key"},
+		{name: "an empty list of entries", repo: "owner/keyless", sig: "keyless.sig", cert: pemFile, entries: []byte("[]"), want: "no key"},
+		{name: "an entry not signed by rekor", repo: "owner/keyless", sig: "keyless.sig", cert: pemFile, entries: rekorResponse(t, forged), want: "invalid"},
+		{name: "an entry recorded at another time", repo: "owner/keyless", sig: "keyless.sig", cert: pemFile, want: "invalid",
+			entries: rekorResponse(t, func(e *rekorEntry) { e.IntegratedTime += 3600 })},
+		{name: "invalid entries", repo: "owner/keyless", sig: "keyless.sig", cert: pemFile, entries: []byte("{"), want: "invalid"},
+		{name: "tampered", repo: "owner/keyless", data: "tampered\n", sig: "keyless.sig", cert: pemFile, entries: entries, want: "invalid"},
+		{name: "signed by another key", repo: "owner/keyless", sig: "asset.txt.sig", cert: pemFile, entries: entries, want: "invalid"},
+		{name: "another certificate", repo: "owner/keyless", sig: "keyless.sig", cert: readTestdata(t, "other_fulcio.pem"), entries: entries, want: "invalid"},
+		{name: "another identity", repo: "owner/wrongid", sig: "keyless.sig", cert: pemFile, entries: entries, want: "invalid"},
+		{name: "another issuer", repo: "owner/wrongissuer", sig: "keyless.sig", cert: pemFile, entries: entries, want: "invalid"},
+		{name: "not a certificate", repo: "owner/keyless", sig: "keyless.sig", cert: readTestdata(t, "cosign.pub"), entries: entries, want: "invalid"},
+		{name: "no identity", repo: "owner/cosign", sig: "keyless.sig", cert: pemFile, entries: entries, want: "no key"},
+		{name: "unknown repo", repo: "owner/unknown", sig: "keyless.sig", cert: pemFile, entries: entries, want: "no key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := readTestdata(t, "asset.txt")
+			if tt.data != "" {
+				data = []byte(tt.data)
+			}
+			by, err := k.VerifyKeyless(tt.repo, bytes.NewReader(data), readTestdata(t, tt.sig), tt.cert, tt.entries)
+			if got := outcome(err); got != tt.want {
+				t.Fatalf("VerifyKeyless() = %q, %v, want %q", by, err, tt.want)
+			}
+			if by != tt.by {
+				t.Errorf("VerifyKeyless() = %q, want %q", by, tt.by)
+			}
+		})
+	}
+}
+
+// Load a trusted-keys file, testdata/ in it is the testdata directory
+func loadKeys(t *testing.T, data string) *Keys {
+	t.Helper()
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(data, "testdata/", dir+"/")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	k, err := LoadKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// Load the keys of owner/keyless with other sigstore roots
+func keysWithSigstore(t *testing.T, sigstore string) *Keys {
+	t.Helper()
+	return loadKeys(t, sigstore+"repos:\n"+
+		"  owner/keyless:\n"+
+		"    identity: https://github.com/owner/keyless/.github/workflows/*\n"+
+		"    issuer: https://token.actions.githubusercontent.com\n")
+}
+
+func TestVerifyKeylessRoots(t *testing.T) {
+	tests := []struct {
+		name     string
+		sigstore string
+		suffix   string
+		sig      string
+		want     string
+	}{
+		{name: "bundle, another fulcio root", sigstore: "sigstore:\n  fulcio_roots: [testdata/other_fulcio.pem]\n  rekor_keys: [testdata/rekor.pub]\n", suffix: ".bundle", sig: "keyless.bundle", want: "invalid"},
+		{name: "bundle, another rekor key", sigstore: "sigstore:\n  fulcio_roots: [testdata/fulcio.pem]\n  rekor_keys: [testdata/cosign.pub]\n", suffix: ".bundle", sig: "keyless.bundle", want: "invalid"},
+		{name: "bundle, no rekor key", sigstore: "sigstore:\n  fulcio_roots: [testdata/fulcio.pem]\n", suffix: ".bundle", sig: "keyless.bundle", want: "no key"},
+		{name: "sig and pem, another fulcio root", sigstore: "sigstore:\n  fulcio_roots: [testdata/other_fulcio.pem]\n  rekor_keys: [testdata/rekor.pub]\n", suffix: ".sig", sig: "keyless.sig", want: "invalid"},
+		{name: "sig and pem, another rekor key", sigstore: "sigstore:\n  fulcio_roots: [testdata/fulcio.pem]\n  rekor_keys: [testdata/cosign.pub]\n", suffix: ".sig", sig: "keyless.sig", want: "invalid"},
+		{name: "sig and pem, no rekor key", sigstore: "sigstore:\n  fulcio_roots: [testdata/fulcio.pem]\n", suffix: ".sig", sig: "keyless.sig", want: "no key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := keysWithSigstore(t, tt.sigstore)
+			data := bytes.NewReader(readTestdata(t, "asset.txt"))
+			var by string
+			var err error
+			if tt.suffix == ".sig" {
+				by, err = k.VerifyKeyless("owner/keyless", data, readTestdata(t, tt.sig), readTestdata(t, "keyless.pem"), rekorResponse(t, nil))
+			} else {
+				by, err = k.Verify("owner/keyless", tt.suffix, data, readTestdata(t, tt.sig))
+			}
+			if got := outcome(err); got != tt.want {
+				t.Fatalf("verify = %q, %v, want %q", by, err, tt.want)
+			}
+		})
+	}
+}
+
+// Return the keyless bundle of testdata with its transparency log entry changed by edit
+func editBundle(t *testing.T, edit func(b *bundle)) []byte {
+	t.Helper()
+	var b bundle
+	if err := json.Unmarshal(readTestdata(t, "keyless.bundle"), &b); err != nil {
+		t.Fatal(err)
+	}
+	edit(&b)
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifyBundleEntry(t *testing.T) {
+	k := testKeys(t)
+	tests := []struct {
+		name string
+		edit func(b *bundle)
+	}{
+		{name: "another log index", edit: func(b *bundle) { b.RekorBundle.Payload.LogIndex++ }},
+		{name: "recorded at another time", edit: func(b *bundle) { b.RekorBundle.Payload.IntegratedTime += 3600 }},
+		{name: "no signed entry timestamp", edit: func(b *bundle) { b.RekorBundle.SignedEntryTimestamp = "" }},
+		{name: "no transparency log entry", edit: func(b *bundle) { b.RekorBundle = nil }},
+		{name: "another certificate", edit: func(b *bundle) {
+			b.Cert = base64.StdEncoding.EncodeToString(readTestdata(t, "other_fulcio.pem"))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := editBundle(t, tt.edit)
+			by, err := k.Verify("owner/keyless", ".bundle", bytes.NewReader(readTestdata(t, "asset.txt")), raw)
+			if outcome(err) != "invalid" {
+				t.Fatalf("Verify() = %q, %v, want an invalid signature", by, err)
+			}
+		})
+	}
+}
+
+func TestVerifyRewinds(t *testing.T) {
+	// data is read from its start, whatever was read from it before
+	k := testKeys(t)
+	for _, tt := range []struct{ repo, suffix, sig string }{
+		{"owner/minisign", ".minisig", "asset.txt.minisig"},
+		{"owner/gpg", ".asc", "asset.txt.asc"},
+		{"owner/cosign", ".sig", "asset.txt.sig"},
+		{"owner/keyless", ".bundle", "keyless.bundle"},
+	} {
+		data := bytes.NewReader(readTestdata(t, "asset.txt"))
+		data.Seek(5, 0)
+		if _, err := k.Verify(tt.repo, tt.suffix, data, readTestdata(t, tt.sig)); err != nil {
+			t.Errorf("Verify(%s) after a read: %v", tt.sig, err)
+		}
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{name: "unknown field", yaml: "repos:\n  owner/repo:\n    minsign: RWQ\n"},
+		{name: "not owner/repo", yaml: "repos:\n  repo:\n    minisign: " + string(bytes.TrimSpace(readTestdata(t, "other_minisign.pub"))) + "\n"},
+		{name: "no key", yaml: "repos:\n  owner/repo:\n    issuer: https://token.actions.githubusercontent.com\n"},
+		{name: "identity without fulcio roots", yaml: "repos:\n  owner/repo:\n    identity: \"*\"\n"},
+		{name: "invalid minisign key", yaml: "repos:\n  owner/repo:\n    minisign: RWQnotakey\n"},
+		{name: "invalid cosign key", yaml: "repos:\n  owner/repo:\n    cosign: notakey\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadKeys(path); err == nil {
+				t.Fatal("LoadKeys() = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"https://github.com/o/r/.github/workflows/*", "https://github.com/o/r/.github/workflows/release.yml@refs/tags/v1", true},
+		{"https://github.com/o/r/.github/workflows/*", "https://github.com/o/r2/.github/workflows/release.yml@refs/tags/v1", false},
+		{"https://github.com/o/r/*", "https://github.com/o/r.evil/x", false},
+		{"https://github.com/o/r/.github/workflows/release.yml@*", "https://github.com/o/r/.github/workflows/release.yml@refs/heads/main", true},
+		// regexp characters are literal
+		{"https://github.com/o/r.github", "https://github.com/o/rXgithub", false},
+		{"*", "anything", true},
+		{"user@example.com", "user@example.com", true},
+		{"user@example.com", "evil-user@example.com", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kavishgr/getghrel/manifest"
+	"github.com/kavishgr/getghrel/options"
+)
+
+// Return whether an installed file is still the one getghrel wrote
+func fileStatus(f manifest.File) string {
+	sum, err := manifest.FileSHA256(f.Path)
+	switch {
+	case os.IsNotExist(err):
+		return "missing"
+	case err != nil:
+		return err.Error()
+	case sum != f.SHA256:
+		return "modified since it was installed"
+	}
+	return "unchanged"
+}
+
+/*
+- Find the tool a binary belongs to in the manifest.
+
+- binary is a path, or a name looked up in the PATH.
+When it's not on the PATH (or the one on the PATH was not installed by getghrel),
+the files and links of the manifest are searched by name.
+*/
+func findBinary(m *manifest.Manifest, binary string) (manifest.Tool, string, bool) {
+	path := binary
+	if !strings.ContainsRune(binary, filepath.Separator) && !strings.Contains(binary, "/") {
+		path, _ = exec.LookPath(binary)
+	}
+	if path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if tool, ok := m.Find(path); ok {
+			return tool, path, true
+		}
+	}
+	return m.FindName(filepath.Base(binary))
+}
+
+// getghrel which <binary>...: print where installed binaries came from
+func whichCommand(args []string) int {
+	opts := options.ParseCommand("which", args)
+	if len(opts.Args) == 0 {
+		fmt.Println("Usage: getghrel which <binary>...")
+		return 1
+	}
+
+	m, err := manifest.Load(manifest.Path(opts.DataDir))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	status := 0
+	for _, binary := range opts.Args {
+		tool, path, ok := findBinary(m, binary)
+		if !ok {
+			fmt.Printf("%s: not installed by getghrel\n", binary)
+			status = 1
+			continue
+		}
+
+		// a link of the tree layout, the checksum is the one of the executable
+		file, ok := tool.File(path)
+		if !ok {
+			if target, err := filepath.EvalSymlinks(path); err == nil {
+				file, ok = tool.File(target)
+			}
+		}
+
+		fmt.Println(path)
+		fmt.Printf("  repo:      %s\n", tool.Repo)
+		fmt.Printf("  version:   %s\n", tool.Tag)
+		fmt.Printf("  asset:     %s\n", tool.Asset)
+		fmt.Printf("  url:       %s\n", tool.URL)
+		fmt.Printf("  platform:  %s\n", tool.Platform)
+		if tool.Verified != "" {
+			fmt.Printf("  verified:  %s\n", tool.Verified)
+		} else {
+			fmt.Printf("  verified:  no\n")
+		}
+		if tool.Signed != "" {
+			fmt.Printf("  signed:    %s\n", tool.Signed)
+		}
+		if tool.Attested != "" {
+			fmt.Printf("  built by:  %s\n", tool.Attested)
+		}
+		if ok {
+			fmt.Printf("  sha256:    %s (%s)\n", file.SHA256, fileStatus(file))
+		}
+		if tool.Dir != "" {
+			fmt.Printf("  directory: %s\n", tool.Dir)
+		}
+		fmt.Printf("  installed: %s\n", tool.Time.Local().Format("2006-01-02 15:04:05"))
+	}
+	return status
+}